@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/secure"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// runCert - "cert" mode: "shortener cert generate [flags]" writes CA and server certificate signed by it
+func runCert(args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		return errors.New("usage: shortener cert generate [flags]")
+	}
+	fs := flag.NewFlagSet("cert generate", flag.ContinueOnError)
+	dir := fs.String("dir", ".", "output directory")
+	hosts := fs.String("hosts", strings.Join(secure.Hosts(config.Default()), ","), "comma separated DNS names and IP addresses of server")
+	validity := fs.Duration("validity", 365*24*time.Hour, "server certificate validity")
+	caValidity := fs.Duration("ca-validity", 10*365*24*time.Hour, "CA certificate validity")
+	force := fs.Bool("force", false, "overwrite existing files")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var names []string
+	for _, h := range strings.Split(*hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			names = append(names, h)
+		}
+	}
+	ca, err := secure.NewAuthority("shortener CA", *caValidity)
+	if err != nil {
+		return err
+	}
+	cert, key, err := ca.Issue(names, *validity)
+	if err != nil {
+		return err
+	}
+	caCert, caKey := filepath.Join(*dir, "ca.crt"), filepath.Join(*dir, "ca.key")
+	if err = secure.WritePair(caCert, caKey, ca.Cert, ca.Key, *force); err != nil {
+		return err
+	}
+	srvCert, srvKey := filepath.Join(*dir, "server.crt"), filepath.Join(*dir, "server.key")
+	if err = secure.WritePair(srvCert, srvKey, cert, key, *force); err != nil {
+		return err
+	}
+	fmt.Println("CA certificate:", caCert)
+	fmt.Println("Server certificate:", srvCert, "for", strings.Join(names, ", "))
+	return nil
+}
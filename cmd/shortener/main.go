@@ -2,22 +2,30 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/Stas9132/shortener/config"
-	"github.com/Stas9132/shortener/internal/app/handlers"
-	"github.com/Stas9132/shortener/internal/app/handlers/middleware"
-	"github.com/Stas9132/shortener/internal/app/storage"
-	"github.com/Stas9132/shortener/internal/gzip"
+	"github.com/Stas9132/shortener/internal/health"
+	"github.com/Stas9132/shortener/internal/lifecycle"
+	"github.com/Stas9132/shortener/internal/listen"
 	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/Stas9132/shortener/internal/metrics"
+	"github.com/Stas9132/shortener/internal/secure"
+	"github.com/Stas9132/shortener/internal/server"
+	"github.com/Stas9132/shortener/internal/tracing"
 	"log"
 	"net"
 	"net/http"
+	"net/http/pprof"
+	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
-	"github.com/go-chi/chi/v5"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var (
@@ -30,86 +38,228 @@ func init() {
 	fmt.Println("Build version:", buildVersion)
 	fmt.Println("Build date:", buildDate)
 	fmt.Println("Build commit:", buildCommit)
+	metrics.SetBuildInfo(buildVersion, buildDate, buildCommit)
 }
 
-func mRouter(handler handlers.APII) {
-	r := chi.NewRouter()
-	r.Use(middleware.RequestLogger, middleware.Authorization, gzip.GzipMiddleware)
-
-	r.Post("/", handler.PostPlainText)
-	r.Get("/{sn}", handler.GetRoot)
-	r.Post("/api/shorten", handler.PostJSON)
-	r.Post("/api/shorten/batch", handler.PostBatch)
-	r.Get("/api/user/urls", handler.GetUserURLs)
-	r.Delete("/api/user/urls", handler.DeleteUserUrls)
-	r.Get("/ping", handler.GetPing)
-	r.NotFound(handler.Default)
-	r.MethodNotAllowed(handler.Default)
-	http.Handle("/", r)
+// runAux serves plain http listener besides the main one, name is used in log
+func runAux(s *http.Server, name string) error {
+	logger.WithFields(map[string]interface{}{
+		"address": s.Addr,
+	}).Info("Starting " + name + " server")
+	if err := s.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serve serves router on listener described by lc
+func serve(s *http.Server, ln net.Listener, lc config.ListenerConfig) error {
+	logger.WithFields(map[string]interface{}{
+		"network": lc.Network,
+		"address": ln.Addr().String(),
+		"tls":     lc.TLS,
+		"h2c":     lc.H2C,
+	}).Info("Starting server")
+
+	var err error
+	if lc.TLS {
+		// certificate comes from s.TLSConfig.GetCertificate
+		err = s.ServeTLS(ln, "", "")
+	} else {
+		err = s.Serve(ln)
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
-func run(s *http.Server, h handlers.APII) {
-	listenSrv := func(f any, parms ...string) {
-		var err error
-		switch t := f.(type) {
-		case func(string, string) error:
-			err = t(parms[0], parms[1])
-		case func() error:
-			err = t()
+// shutdownServers stops accepting connections and waits for in-flight requests,
+// connections still open when ctx is done are closed
+func shutdownServers(ctx context.Context, servers []*http.Server) error {
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, s := range servers {
+		wg.Add(1)
+		go func(i int, s *http.Server) {
+			defer wg.Done()
+			if errs[i] = s.Shutdown(ctx); errs[i] != nil {
+				s.Close()
+			}
+		}(i, s)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// httpsAddress - address of first https tcp listener, redirect target
+func httpsAddress(c config.Config) string {
+	for _, lc := range c.ListenerList() {
+		if lc.TLS && lc.Network == "tcp" {
+			return lc.Address
 		}
-		if err != nil {
-			t := &net.OpError{}
-			if errors.As(err, &t) {
-				log.Fatal(err)
-			} else {
+	}
+	return c.ServerAddress
+}
+
+// adminHandler - metrics, health and profiling routes of admin listener
+func adminHandler(ready http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", health.Live())
+	mux.Handle("/readyz", ready)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// reloadOnHUP reopens log output and reloads config on SIGHUP or config file change
+func reloadOnHUP(ctx context.Context, cfg *config.Holder) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	changed := cfg.Watch(ctx, time.Duration(cfg.Get().ConfigWatchInterval))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := logger.Reopen(); err != nil {
 				log.Println(err)
 			}
+		case <-changed:
 		}
+		reloadConfig(cfg)
 	}
+}
 
-	logger.WithFields(map[string]interface{}{
-		"address": config.C.ServerAddress,
-	}).Infoln("Starting server")
-
-	mRouter(h)
+func reloadConfig(cfg *config.Holder) {
+	refused, err := cfg.Reload()
+	if err != nil {
+		logger.WithField("error", err).Error("Error while reload config")
+		return
+	}
+	for _, name := range refused {
+		logger.WithField("setting", name).Warn("Config change requires restart, ignored")
+	}
+	logger.Info("Config reloaded")
+}
 
-	if config.C.SecureConnection {
-		listenSrv(s.ListenAndServeTLS, "server.crt", "server.key")
-	} else {
-		listenSrv(s.ListenAndServe)
+func applyLogLevel(old, new config.Config) {
+	if old.LogLevel == new.LogLevel {
+		return
 	}
+	if err := logger.SetLevel(new.LogLevel); err != nil {
+		logger.WithField("error", err).Error("Error while change log level")
+		return
+	}
+	logger.WithField("level", new.LogLevel).Info("Log level changed")
 }
 
 func main() {
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	if len(os.Args) > 1 && os.Args[1] == "cert" {
+		if err := runCert(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := run(); err != nil {
+		log.Println(err)
+		os.Exit(1)
+	}
+}
+
+// run starts shortener and shuts it down on signal or failure of any server, error means unclean exit
+func run() error {
+	sigCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer stop()
+	// background loops outlive the signal until their shutdown step, async work may still need them
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	config.Init(ctx)
+	cfg, err := config.Init(ctx)
+	if err != nil {
+		return err
+	}
+	c := cfg.Get()
 
-	l, err := logger.NewLogrusLogger(ctx)
+	l, err := logger.New(ctx, c)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	var st handlers.StorageI
-	if len(config.C.DatabaseDsn) == 0 {
-		st, err = storage.NewFileStorage(ctx, l)
-		if err != nil {
-			log.Fatal(err)
+	defer logger.CloseOutput()
+	cfg.Subscribe(applyLogLevel)
+	go reloadOnHUP(ctx, cfg)
+
+	m := lifecycle.New(l)
+	closeTimeout := time.Duration(c.Shutdown.CloseTimeout)
+	shutdownTracing, err := tracing.Init(ctx, c.Tracing)
+	if err != nil {
+		return err
+	}
+	m.OnShutdown("tracing", closeTimeout, shutdownTracing)
+	srv, err := server.New(ctx, l, cfg)
+	if err != nil {
+		return errors.Join(err, m.Shutdown())
+	}
+	m.OnShutdown("storage", closeTimeout, func(context.Context) error { return srv.Close() })
+	m.OnShutdown("background", 0, func(context.Context) error {
+		cancel()
+		return nil
+	})
+	m.OnShutdown("async", time.Duration(c.Shutdown.AsyncTimeout), srv.Wait)
+
+	var servers []*http.Server
+	m.OnShutdown("http", time.Duration(c.Shutdown.DrainTimeout), func(ctx context.Context) error {
+		return shutdownServers(ctx, servers)
+	})
+	m.OnShutdown("readiness", 0, func(context.Context) error {
+		srv.Drain()
+		time.Sleep(time.Duration(c.Shutdown.ReadinessDelay))
+		return nil
+	})
+
+	var tc *tls.Config
+	if c.ServesTLS() {
+		if tc, err = secure.TLSConfig(ctx, l, cfg, secure.Hosts(c)); err != nil {
+			return errors.Join(err, m.Shutdown())
 		}
-	} else {
-		st, err = storage.NewDB(ctx, l)
+		if c.TLS.RedirectAddress != "" {
+			redirect := &http.Server{Addr: c.TLS.RedirectAddress, Handler: secure.Redirect(httpsAddress(c))}
+			servers = append(servers, redirect)
+			m.Go("redirect", func() error { return runAux(redirect, "redirect") })
+		}
+	}
+	for _, lc := range c.ListenerList() {
+		lc := lc
+		ln, err := listen.Open(lc)
 		if err != nil {
-			log.Fatal(err)
+			return errors.Join(err, m.Shutdown())
+		}
+		s := &http.Server{Handler: srv}
+		if lc.H2C {
+			s.Handler = h2c.NewHandler(srv, &http2.Server{})
+		}
+		if lc.TLS {
+			// each server sets its own ALPN protocols
+			s.TLSConfig = tc.Clone()
 		}
+		servers = append(servers, s)
+		m.Go(lc.Network+" "+lc.Address, func() error { return serve(s, ln, lc) })
+	}
+	if c.AdminAddress != "" {
+		admin := &http.Server{Addr: c.AdminAddress, Handler: adminHandler(srv.Health())}
+		servers = append(servers, admin)
+		m.Go("admin", func() error { return runAux(admin, "admin") })
 	}
-	h := handlers.NewAPI(ctx, l, st)
-	s := &http.Server{Addr: config.C.ServerAddress}
-	go run(s, h)
-
-	<-ctx.Done()
 
-	ctx, cansel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cansel()
-	s.Shutdown(ctx)
-	st.Close()
+	select {
+	case <-sigCtx.Done():
+		l.Info("Shutting down")
+	case <-m.Failed():
+	}
+	return m.Shutdown()
 }
@@ -3,87 +3,439 @@ package config
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
-	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config - ...
+// Fields tagged reload:"restart" are applied only at startup, Reload keeps their current values.
 type Config struct {
-	ServerAddress    string `json:"server_address"`
-	BaseURL          string `json:"base_url"`
-	LogLevel         string `json:"log_level"`
-	FileStoragePath  string `json:"file_storage_path"`
-	DatabaseDsn      string `json:"database_dsn"`
-	SecureConnection bool   `json:"enable_https"`
-}
-
-// C - ...
-var C = Config{
-	ServerAddress:    "localhost:8080",
-	BaseURL:          "http://localhost:8080/",
-	LogLevel:         "info",
-	FileStoragePath:  "",
-	DatabaseDsn:      "",
-	SecureConnection: false,
-}
-
-// Init - config initiator
-func Init(ctx context.Context) {
-	var config string
-
-	def := C
-
-	flagSet := flag.NewFlagSet("config", flag.ContinueOnError)
-	flagSet.StringVar(&config, "c", "", "name of config")
-	if err := flagSet.Parse(os.Args[1:]); err != nil {
-		log.Println(err)
-	}
-	if b, err := os.ReadFile(config); err == nil {
-		if err = json.Unmarshal(b, &C); err != nil {
-			log.Println(err)
+	ServerAddress string `json:"server_address" reload:"restart"`
+	BaseURL       string `json:"base_url"`
+	// Domains - short link domains resolved by request Host, first one is default.
+	// Empty serves single domain DefaultDomainID at BaseURL.
+	Domains  []DomainConfig `json:"domains"`
+	LogLevel string         `json:"log_level"`
+	// LogBackend - "logrus" or "slog"
+	LogBackend string `json:"log_backend" reload:"restart"`
+	// LogFormat - "text" or "json"
+	LogFormat string `json:"log_format" reload:"restart"`
+	// LogOutput - "stderr", "stdout", "file" or "syslog"
+	LogOutput        string         `json:"log_output" reload:"restart"`
+	LogFile          LogFileConfig  `json:"log_file" reload:"restart"`
+	LogSyslogTag     string         `json:"log_syslog_tag" reload:"restart"`
+	FileStoragePath  string         `json:"file_storage_path" reload:"restart"`
+	DatabaseDsn      string         `json:"database_dsn" reload:"restart"`
+	SecureConnection bool           `json:"enable_https" reload:"restart"`
+	TLS              TLSConfig      `json:"tls"`
+	URL              URLConfig      `json:"url" reload:"restart"`
+	Compress         CompressConfig `json:"compress" reload:"restart"`
+	// BlocklistPath - file with blocked destination hosts
+	BlocklistPath string `json:"blocklist_path"`
+	// BlocklistReloadInterval - period of blocklist file change checks, 0 disables reload
+	BlocklistReloadInterval Duration `json:"blocklist_reload_interval" reload:"restart"`
+	// AdminToken - value of X-Admin-Token header required by admin routes, empty disables them
+	AdminToken string       `json:"admin_token"`
+	Safety     SafetyConfig `json:"safety" reload:"restart"`
+	// Listeners - sockets serving the router, empty serves ServerAddress alone
+	Listeners []ListenerConfig `json:"listeners" reload:"restart"`
+	// AdminAddress - address of admin listener serving /metrics and /debug/pprof, empty disables it
+	AdminAddress string        `json:"admin_address" reload:"restart"`
+	Tracing      TracingConfig `json:"tracing" reload:"restart"`
+	// ConfigWatchInterval - period of config file change checks, 0 disables watching, SIGHUP always reloads
+	ConfigWatchInterval Duration       `json:"config_watch_interval" reload:"restart"`
+	Shutdown            ShutdownConfig `json:"shutdown" reload:"restart"`
+	Health              HealthConfig   `json:"health" reload:"restart"`
+	Cache               CacheConfig    `json:"cache" reload:"restart"`
+	Bloom               BloomConfig    `json:"bloom" reload:"restart"`
+	Replicas            ReplicaConfig  `json:"replicas" reload:"restart"`
+}
+
+// ReplicaConfig - read replicas of DatabaseDsn
+type ReplicaConfig struct {
+	// DSNs - replicas serving reads, empty reads from primary
+	DSNs []string `json:"dsns"`
+	// CheckInterval - period of replica health checks
+	CheckInterval Duration `json:"check_interval"`
+	// CheckTimeout - limit of each health check
+	CheckTimeout Duration `json:"check_timeout"`
+	// MaxLag - replicas replaying changes later than this are not used, zero disables lag check
+	MaxLag Duration `json:"max_lag"`
+	// ReadYourWrites - period user reads from primary after creating link, zero disables pinning
+	ReadYourWrites Duration `json:"read_your_writes"`
+}
+
+// BloomConfig - in-memory filter of existing codes answering lookups of unknown codes without database query
+type BloomConfig struct {
+	// Enabled - used with database storage only
+	Enabled           bool    `json:"enabled"`
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+	// Capacity - minimum number of codes filter is sized for, on build it is sized for twice the stored codes
+	Capacity int `json:"capacity"`
+	// RebuildInterval - period of rebuilds dropping deleted codes, zero disables them
+	RebuildInterval Duration `json:"rebuild_interval"`
+}
+
+// CacheConfig - cache of link resolutions in front of storage
+type CacheConfig struct {
+	// Size - maximum number of cached links, zero disables the cache
+	Size int `json:"size"`
+	// TTL - lifetime of cached link, bounds staleness of changes made by other instances
+	TTL Duration `json:"ttl"`
+	// NegativeTTL - lifetime of cached unknown code, zero disables negative caching
+	NegativeTTL Duration `json:"negative_ttl"`
+	// FallbackTTL - cap of lifetimes while invalidations from database are not received
+	FallbackTTL Duration `json:"fallback_ttl"`
+}
+
+// HealthConfig - readiness checks of /readyz
+type HealthConfig struct {
+	// Timeout - limit of each check
+	Timeout Duration `json:"timeout"`
+	// CacheTTL - check results are reused for this long so probes do not hammer dependencies, zero disables caching
+	CacheTTL Duration `json:"cache_ttl"`
+	// MaxDeleteBacklog - pending asynchronous deletions above which service is not ready, zero disables the check
+	MaxDeleteBacklog int `json:"max_delete_backlog"`
+}
+
+// ShutdownConfig - graceful shutdown timeouts, zero waits indefinitely
+type ShutdownConfig struct {
+	// ReadinessDelay - pause between failing readiness and closing listeners so load balancers stop routing, zero skips it
+	ReadinessDelay Duration `json:"readiness_delay"`
+	// DrainTimeout - wait for in-flight requests, connections still open after it are closed
+	DrainTimeout Duration `json:"drain_timeout"`
+	// AsyncTimeout - wait for work started by requests in background, e.g. deletions
+	AsyncTimeout Duration `json:"async_timeout"`
+	// CloseTimeout - wait for each resource to flush and close
+	CloseTimeout Duration `json:"close_timeout"`
+}
+
+// TLSConfig - https settings used when enable_https is set
+type TLSConfig struct {
+	// CertFile, KeyFile - served certificate, new paths are loaded on reload
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ReloadInterval - period of certificate file change checks, 0 disables reload
+	ReloadInterval Duration `json:"reload_interval" reload:"restart"`
+	// MinVersion - "1.2" or "1.3"
+	MinVersion string `json:"min_version" reload:"restart"`
+	// CipherSuites - crypto/tls names of TLS 1.2 suites allowed, empty uses Go defaults
+	CipherSuites []string `json:"cipher_suites" reload:"restart"`
+	// RedirectAddress - address of plain http listener redirecting to https, empty disables it
+	RedirectAddress string `json:"redirect_address" reload:"restart"`
+	// HSTSMaxAge - max-age of Strict-Transport-Security header, 0 disables the header
+	HSTSMaxAge            Duration `json:"hsts_max_age" reload:"restart"`
+	HSTSIncludeSubdomains bool     `json:"hsts_include_subdomains" reload:"restart"`
+	// ClientCAFile - CA bundle verifying client certificates, empty disables mutual TLS
+	ClientCAFile string `json:"client_ca_file" reload:"restart"`
+	// ClientCertRoutes - paths requiring verified client certificate, "/prefix/*" matches subtree
+	ClientCertRoutes []string `json:"client_cert_routes"`
+	// ClientIdentities - issuer id by certificate URI, DNS or email SAN, common name or subject,
+	// unmapped certificates are identified by first of them present
+	ClientIdentities map[string]string `json:"client_identities"`
+}
+
+// TracingConfig - OpenTelemetry settings
+type TracingConfig struct {
+	// Exporter - "otlp", "stdout" or empty to disable tracing
+	Exporter string `json:"exporter"`
+	// Endpoint - host:port of OTLP/HTTP collector
+	Endpoint    string  `json:"endpoint"`
+	Insecure    bool    `json:"insecure"`
+	ServiceName string  `json:"service_name"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// SafetyConfig - url reputation check settings
+type SafetyConfig struct {
+	// Provider - "http" for Safe-Browsing-compatible api, "local" for hash prefix file, empty disables checks
+	Provider  string `json:"provider"`
+	Endpoint  string `json:"endpoint"`
+	APIKey    string `json:"api_key"`
+	LocalPath string `json:"local_path"`
+	// CacheSize - maximum number of cached verdicts, zero disables the cache
+	CacheSize int `json:"cache_size"`
+	// CacheTTL, RescanInterval - zero disables cache and rescan
+	Timeout        Duration `json:"timeout"`
+	CacheTTL       Duration `json:"cache_ttl"`
+	RescanInterval Duration `json:"rescan_interval"`
+}
+
+// LogFileConfig - rotating log file settings
+type LogFileConfig struct {
+	Path string `json:"path"`
+	// MaxSize, MaxAge - rotation thresholds, zero disables the check
+	MaxSize Size     `json:"max_size"`
+	MaxAge  Duration `json:"max_age"`
+	// MaxBackups - number of rotated segments kept, zero keeps all
+	MaxBackups int  `json:"max_backups"`
+	Compress   bool `json:"compress"`
+}
+
+// CompressConfig - response compression and request decoding settings
+type CompressConfig struct {
+	// Encodings - supported content codings in order of preference: "br", "zstd", "gzip", "deflate", empty disables compression
+	Encodings []string `json:"encodings"`
+	// MinSize - responses shorter than this are sent uncompressed
+	MinSize Size `json:"min_size"`
+	// MaxRequestSize - limit of request body as sent, MaxDecodedSize - limit of request body after decoding, zero disables the limit
+	MaxRequestSize Size `json:"max_request_size"`
+	MaxDecodedSize Size `json:"max_decoded_size"`
+}
+
+// URLConfig - destination url validation settings
+type URLConfig struct {
+	AllowedSchemes     []string `json:"allowed_schemes"`
+	MaxLength          int      `json:"max_length"`
+	StripTrailingSlash bool     `json:"strip_trailing_slash"`
+	SortQuery          bool     `json:"sort_query"`
+	BlockPrivate       bool     `json:"block_private"`
+	// LookupTimeout - limit of host resolution when BlockPrivate is set, failed resolution rejects url
+	LookupTimeout Duration `json:"lookup_timeout"`
+}
+
+// Default - returns config with default values
+func Default() Config {
+	return Config{
+		ServerAddress: "localhost:8080",
+		BaseURL:       "http://localhost:8080/",
+		LogLevel:      "info",
+		LogBackend:    "logrus",
+		LogFormat:     "text",
+		LogOutput:     "stderr",
+		LogFile: LogFileConfig{
+			Path:       "shortener.log",
+			MaxSize:    100 << 20,
+			MaxBackups: 7,
+			Compress:   true,
+		},
+		LogSyslogTag:     "shortener",
+		FileStoragePath:  "",
+		DatabaseDsn:      "",
+		SecureConnection: false,
+		TLS: TLSConfig{
+			CertFile:         "server.crt",
+			KeyFile:          "server.key",
+			ReloadInterval:   Duration(time.Minute),
+			MinVersion:       "1.2",
+			ClientCertRoutes: []string{"/api/internal/*"},
+		},
+		URL: URLConfig{
+			AllowedSchemes: []string{"http", "https"},
+			MaxLength:      2048,
+			LookupTimeout:  Duration(2 * time.Second),
+		},
+		Compress: CompressConfig{
+			Encodings:      []string{"br", "zstd", "gzip", "deflate"},
+			MinSize:        1 << 10,
+			MaxRequestSize: 1 << 20,
+			MaxDecodedSize: 8 << 20,
+		},
+		BlocklistReloadInterval: Duration(30 * time.Second),
+		Safety: SafetyConfig{
+			Timeout:   Duration(5 * time.Second),
+			CacheSize: 10000,
+			CacheTTL:  Duration(10 * time.Minute),
+		},
+		Tracing: TracingConfig{
+			Endpoint:    "localhost:4318",
+			ServiceName: "shortener",
+			SampleRatio: 1,
+		},
+		Shutdown: ShutdownConfig{
+			DrainTimeout: Duration(10 * time.Second),
+			AsyncTimeout: Duration(10 * time.Second),
+			CloseTimeout: Duration(5 * time.Second),
+		},
+		Health: HealthConfig{
+			Timeout:          Duration(2 * time.Second),
+			CacheTTL:         Duration(5 * time.Second),
+			MaxDeleteBacklog: 10000,
+		},
+		Cache: CacheConfig{
+			Size:        10000,
+			TTL:         Duration(time.Minute),
+			NegativeTTL: Duration(5 * time.Second),
+			FallbackTTL: Duration(5 * time.Second),
+		},
+		Bloom: BloomConfig{
+			Enabled:           true,
+			FalsePositiveRate: 0.01,
+			Capacity:          1000000,
+			RebuildInterval:   Duration(time.Hour),
+		},
+		Replicas: ReplicaConfig{
+			CheckInterval:  Duration(5 * time.Second),
+			CheckTimeout:   Duration(2 * time.Second),
+			MaxLag:         Duration(10 * time.Second),
+			ReadYourWrites: Duration(5 * time.Second),
+		},
+	}
+}
+
+// Init - loads config from command line, see load for precedence.
+// With -print-config prints effective config with secrets redacted and exits.
+func Init(ctx context.Context) (*Holder, error) {
+	c, o, err := load(os.Args[1:], flag.ExitOnError)
+	if o.printConfig {
+		if perr := Print(os.Stdout, c); perr != nil {
+			log.Fatal(perr)
+		}
+		if err != nil {
+			log.Fatal(err)
 		}
+		os.Exit(0)
 	}
+	if err != nil {
+		return nil, err
+	}
+	h := NewHolder(c)
+	h.args, h.path, h.loaded = os.Args[1:], o.path, true
+	return h, nil
+}
 
-	d := C
+// options - command line flags which are not config settings
+type options struct {
+	path        string
+	printConfig bool
+}
 
-	flag.StringVar(&config, "c", "", "name of config")
-	flag.StringVar(&d.ServerAddress, "a", "localhost:8080", "Address of http server")
-	flag.StringVar(&d.BaseURL, "b", "http://localhost:8080/", "Response prefix")
-	flag.StringVar(&d.LogLevel, "l", "info", "Set log level")
-	flag.StringVar(&d.FileStoragePath, "f", "", "Storage file name")
-	flag.StringVar(&d.DatabaseDsn, "d", "", "Database dsn")
-	flag.BoolVar(&d.SecureConnection, "s", false, "")
+// load builds config from defaults < config file given by -c < environment < flags, later ones win.
+// Returned error joins all problems found, config is filled as far as possible anyway.
+func load(arguments []string, errorHandling flag.ErrorHandling) (Config, options, error) {
+	c := Default()
+	var o options
+	f := c
 
-	flag.Parse()
+	flagSet := flag.NewFlagSet("shortener", errorHandling)
+	if errorHandling == flag.ContinueOnError {
+		flagSet.SetOutput(io.Discard)
+	}
+	flagSet.StringVar(&o.path, "c", "", "name of config file, format by extension: .json, .yaml, .yml or .toml")
+	flagSet.BoolVar(&o.printConfig, "print-config", false, "print effective config with secrets redacted and exit")
+	flagSet.StringVar(&f.ServerAddress, "a", f.ServerAddress, "Address of http server")
+	flagSet.StringVar(&f.BaseURL, "b", f.BaseURL, "Response prefix")
+	flagSet.StringVar(&f.LogLevel, "l", f.LogLevel, "Set log level")
+	flagSet.StringVar(&f.FileStoragePath, "f", f.FileStoragePath, "Storage file name")
+	flagSet.StringVar(&f.DatabaseDsn, "d", f.DatabaseDsn, "Database dsn")
+	flagSet.BoolVar(&f.SecureConnection, "s", f.SecureConnection, "Enable https")
+	if err := flagSet.Parse(arguments); err != nil {
+		return c, o, err
+	}
 
-	dv := reflect.ValueOf(d)
-	Cv := reflect.ValueOf(&C).Elem()
-	defV := reflect.ValueOf(def)
-	for i := 0; i < dv.NumField(); i++ {
-		if !dv.Field(i).Equal(defV.Field(i)) {
-			Cv.Field(i).Set(dv.Field(i))
+	var errs []error
+	if o.path != "" {
+		errs = append(errs, decodeFile(o.path, &c))
+	}
+	errs = append(errs, applyEnv(&c)...)
+	flagSet.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "a":
+			c.ServerAddress = f.ServerAddress
+		case "b":
+			c.BaseURL = f.BaseURL
+		case "l":
+			c.LogLevel = f.LogLevel
+		case "f":
+			c.FileStoragePath = f.FileStoragePath
+		case "d":
+			c.DatabaseDsn = f.DatabaseDsn
+		case "s":
+			c.SecureConnection = f.SecureConnection
 		}
+	})
+	errs = append(errs, c.Validate())
+	return c, o, errors.Join(errs...)
+}
+
+// envBool parses boolean environment variable, besides strconv.ParseBool forms accepts yes/no and on/off
+func envBool(name string, dst *bool) error {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
 	}
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "yes", "on":
+		*dst = true
+		return nil
+	case "no", "off":
+		*dst = false
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: invalid boolean %q", name, v)
+	}
+	*dst = b
+	return nil
+}
 
+func applyEnv(c *Config) (errs []error) {
 	if v, ok := os.LookupEnv("SERVER_ADDRESS"); ok {
-		C.ServerAddress = v
+		c.ServerAddress = v
 	}
 	if v, ok := os.LookupEnv("BASE_URL"); ok {
-		C.BaseURL = v
+		c.BaseURL = v
 	}
 	if v, ok := os.LookupEnv("LOG_LEVEL"); ok {
-		C.LogLevel = v
+		c.LogLevel = v
+	}
+	if v, ok := os.LookupEnv("LOG_BACKEND"); ok {
+		c.LogBackend = v
+	}
+	if v, ok := os.LookupEnv("LOG_FORMAT"); ok {
+		c.LogFormat = v
+	}
+	if v, ok := os.LookupEnv("LOG_OUTPUT"); ok {
+		c.LogOutput = v
+	}
+	if v, ok := os.LookupEnv("LOG_FILE"); ok {
+		c.LogFile.Path = v
 	}
 	if v, ok := os.LookupEnv("FILE_STORAGE_PATH"); ok {
-		C.FileStoragePath = v
+		c.FileStoragePath = v
 	}
 	if v, ok := os.LookupEnv("DATABASE_DSN"); ok {
-		C.DatabaseDsn = v
+		c.DatabaseDsn = v
+	}
+	if v, ok := os.LookupEnv("DATABASE_REPLICA_DSNS"); ok {
+		c.Replicas.DSNs = nil
+		if v != "" {
+			c.Replicas.DSNs = strings.Split(v, ",")
+		}
+	}
+	errs = append(errs, envBool("ENABLE_HTTPS", &c.SecureConnection))
+	if v, ok := os.LookupEnv("TLS_CERT_FILE"); ok {
+		c.TLS.CertFile = v
+	}
+	if v, ok := os.LookupEnv("TLS_KEY_FILE"); ok {
+		c.TLS.KeyFile = v
+	}
+	errs = append(errs, envBool("BLOCK_PRIVATE_URLS", &c.URL.BlockPrivate))
+	if v, ok := os.LookupEnv("BLOCKLIST_PATH"); ok {
+		c.BlocklistPath = v
+	}
+	if v, ok := os.LookupEnv("ADMIN_TOKEN"); ok {
+		c.AdminToken = v
+	}
+	if v, ok := os.LookupEnv("ADMIN_ADDRESS"); ok {
+		c.AdminAddress = v
+	}
+	if v, ok := os.LookupEnv("TRACING_EXPORTER"); ok {
+		c.Tracing.Exporter = v
+	}
+	if v, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT"); ok {
+		c.Tracing.Endpoint = v
 	}
-	if v, ok := os.LookupEnv("ENABLE_HTTPS"); ok && v == "YES" {
-		C.SecureConnection = true
+	if v, ok := os.LookupEnv("SAFETY_API_KEY"); ok {
+		c.Safety.APIKey = v
 	}
+	return errs
 }
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	p := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	return p
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeFile(t, "config.json", `{"server_address":"file:1","base_url":"http://file/","log_level":"warn"}`)
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want Config
+	}{
+		{name: "Defaults", want: Config{ServerAddress: "localhost:8080", BaseURL: "http://localhost:8080/", LogLevel: "info"}},
+		{name: "File over defaults", args: []string{"-c", file},
+			want: Config{ServerAddress: "file:1", BaseURL: "http://file/", LogLevel: "warn"}},
+		{name: "Env over file", args: []string{"-c", file}, env: map[string]string{"SERVER_ADDRESS": "env:2"},
+			want: Config{ServerAddress: "env:2", BaseURL: "http://file/", LogLevel: "warn"}},
+		{name: "Flags over env", args: []string{"-c", file, "-a", "flag:3", "-l", "debug"},
+			env:  map[string]string{"SERVER_ADDRESS": "env:2", "LOG_LEVEL": "error", "BASE_URL": "http://env/"},
+			want: Config{ServerAddress: "flag:3", BaseURL: "http://env/", LogLevel: "debug"}},
+		{name: "Flag equal to default still wins", args: []string{"-c", file, "-a", "localhost:8080"},
+			want: Config{ServerAddress: "localhost:8080", BaseURL: "http://file/", LogLevel: "warn"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			c, _, err := load(tt.args, flag.ContinueOnError)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.ServerAddress, c.ServerAddress)
+			assert.Equal(t, tt.want.BaseURL, c.BaseURL)
+			assert.Equal(t, tt.want.LogLevel, c.LogLevel)
+		})
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "JSON", file: "c.json", content: `{"base_url":"http://x.test/","blocklist_reload_interval":"1m",
+"log_file":{"max_size":"10MB","max_age":3600},"url":{"allowed_schemes":["https"]}}`},
+		{name: "YAML", file: "c.yaml", content: `
+base_url: http://x.test/
+blocklist_reload_interval: 1m
+log_file:
+  max_size: 10MB
+  max_age: 3600
+url:
+  allowed_schemes: [https]
+`},
+		{name: "TOML", file: "c.toml", content: `
+base_url = "http://x.test/"
+blocklist_reload_interval = "1m"
+[log_file]
+max_size = "10MB"
+max_age = 3600
+[url]
+allowed_schemes = ["https"]
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _, err := load([]string{"-c", writeFile(t, tt.file, tt.content)}, flag.ContinueOnError)
+			require.NoError(t, err)
+			assert.Equal(t, "http://x.test/", c.BaseURL)
+			assert.Equal(t, Duration(time.Minute), c.BlocklistReloadInterval)
+			assert.Equal(t, Size(10<<20), c.LogFile.MaxSize)
+			assert.Equal(t, Duration(time.Hour), c.LogFile.MaxAge)
+			assert.Equal(t, []string{"https"}, c.URL.AllowedSchemes)
+			assert.Equal(t, 7, c.LogFile.MaxBackups, "defaults kept for missing keys")
+		})
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		env   map[string]string
+		wants []string
+	}{
+		{name: "All validation errors listed",
+			args:  []string{"-a", "localhost", "-b", "example.com/", "-l", "loud"},
+			wants: []string{"server_address", "base_url: scheme", "log_level"}},
+		{name: "Unparseable file", args: []string{"-c", "broken.json"}, wants: []string{"broken.json"}},
+		{name: "Unknown key", args: []string{"-c", "unknown.json"}, wants: []string{"unknown field"}},
+		{name: "Unknown format", args: []string{"-c", "c.ini"}, wants: []string{"unknown format"}},
+		{name: "Bad duration", args: []string{"-c", "duration.json"}, wants: []string{"invalid duration"}},
+		{name: "Bad bool env", env: map[string]string{"ENABLE_HTTPS": "maybe"}, wants: []string{"ENABLE_HTTPS"}},
+		{name: "Base url shadowing api", args: []string{"-b", "http://x/api/"},
+			wants: []string{"base_url: path must not be under /api/"}},
+		{name: "Dependent settings", env: map[string]string{"LOG_OUTPUT": "file", "LOG_FILE": ""},
+			wants: []string{"log_file.path"}},
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"broken.json":   `{"base_url":`,
+		"unknown.json":  `{"base_ulr":"http://x/"}`,
+		"c.ini":         `base_url=http://x/`,
+		"duration.json": `{"config_watch_interval":"soon"}`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := append([]string(nil), tt.args...)
+			for i := range args {
+				if i > 0 && args[i-1] == "-c" {
+					args[i] = filepath.Join(dir, args[i])
+				}
+			}
+			_, _, err := load(args, flag.ContinueOnError)
+			require.Error(t, err)
+			for _, w := range tt.wants {
+				assert.Contains(t, err.Error(), w)
+			}
+		})
+	}
+}
+
+func TestEnvBool(t *testing.T) {
+	for v, want := range map[string]bool{"YES": true, "true": true, "1": true, "on": true, "no": false, "false": false} {
+		t.Setenv("ENABLE_HTTPS", v)
+		c, _, err := load(nil, flag.ContinueOnError)
+		require.NoError(t, err)
+		assert.Equal(t, want, c.SecureConnection, v)
+	}
+}
+
+func TestPrint_Redacted(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{dsn: "postgres://user:secret@db:5432/app", want: "postgres://user:REDACTED@db:5432/app"},
+		{dsn: "host=db user=user password=secret dbname=app", want: "host=db user=user password=REDACTED dbname=app"},
+		{dsn: "postgres://db/app", want: "postgres://db/app"},
+	}
+	for _, tt := range tests {
+		c := Default()
+		c.DatabaseDsn = tt.dsn
+		c.Replicas.DSNs = []string{tt.dsn}
+		c.AdminToken = "token"
+		c.Safety.APIKey = "key"
+		var buf bytes.Buffer
+		require.NoError(t, Print(&buf, c))
+		assert.NotContains(t, buf.String(), "secret")
+		assert.NotContains(t, buf.String(), `"token"`)
+		assert.NotContains(t, buf.String(), `"key"`)
+		assert.Contains(t, buf.String(), tt.want)
+		assert.Contains(t, buf.String(), `"max_size": "100MB"`)
+		assert.Contains(t, buf.String(), `"blocklist_reload_interval": "30s"`)
+	}
+}
+
+func TestSize(t *testing.T) {
+	for in, want := range map[string]Size{"512": 512, "1K": 1 << 10, "64KiB": 64 << 10, "10mb": 10 << 20, "2 GB": 2 << 30} {
+		var s Size
+		require.NoError(t, s.UnmarshalText([]byte(in)), in)
+		assert.Equal(t, want, s, in)
+	}
+	var s Size
+	assert.Error(t, s.UnmarshalText([]byte("1.5GB")))
+	assert.Error(t, s.UnmarshalText([]byte("MB")))
+}
+
+func TestConfig_DomainByHost(t *testing.T) {
+	c := Default()
+	assert.Equal(t, DefaultDomainID, c.DomainByHost("anything").ID)
+	assert.Equal(t, "localhost:8080", c.DomainList()[0].Host)
+
+	c.Domains = []DomainConfig{
+		{ID: "main", BaseURL: "https://sho.rt/"},
+		{ID: "promo", Host: "Promo.Test", BaseURL: "https://promo.test/go/"},
+		{ID: "dev", BaseURL: "http://dev.test:8080/"},
+	}
+	require.NoError(t, c.Validate())
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "sho.rt", want: "main"},
+		{host: "sho.rt:443", want: "main"},
+		{host: "promo.test", want: "promo"},
+		{host: "PROMO.TEST.", want: "promo"},
+		{host: "dev.test:8080", want: "dev"},
+		{host: "dev.test:9090", want: "main"},
+		{host: "unknown.test", want: "main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.DomainByHost(tt.host).ID)
+		})
+	}
+
+	c.Domains = append(c.Domains, DomainConfig{ID: "main", BaseURL: "ftp://x/", DefaultRedirect: "/home"},
+		DomainConfig{ID: "copy", Host: "sho.rt", BaseURL: "https://sho.rt/x/"})
+	err := c.Validate()
+	for _, want := range []string{"domains[3].id: duplicate", "domains[3].base_url", "domains[3].default_redirect", "domains[4].host: duplicate"} {
+		assert.ErrorContains(t, err, want)
+	}
+}
+
+func TestConfig_ListenerList(t *testing.T) {
+	c := Default()
+	c.SecureConnection = true
+	assert.Equal(t, []ListenerConfig{{Network: "tcp", Address: "localhost:8080", TLS: true}}, c.ListenerList())
+
+	c.SecureConnection = false
+	c.Listeners = []ListenerConfig{
+		{Network: "tcp", Address: ":8443", TLS: true},
+		{Network: "unix", Address: "/run/shortener.sock", Mode: "0660", H2C: true},
+		{Network: "systemd", Address: "http"},
+	}
+	require.NoError(t, c.Validate())
+	assert.Equal(t, c.Listeners, c.ListenerList())
+	assert.True(t, c.ServesTLS())
+	mode, err := c.Listeners[1].SocketMode()
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), mode)
+
+	c.Listeners = append(c.Listeners,
+		ListenerConfig{Network: "udp", Address: ":53"},
+		ListenerConfig{Network: "tcp", Mode: "0600"},
+		ListenerConfig{Network: "unix", Address: "/tmp/s", Mode: "rw", TLS: true, H2C: true},
+	)
+	err = c.Validate()
+	for _, want := range []string{"listeners[3].network", "listeners[4].address: required", "listeners[4].mode: only unix",
+		"listeners[5].mode", "listeners[5]: h2c"} {
+		assert.ErrorContains(t, err, want)
+	}
+}
+
+func TestValidate_Bloom(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		rate    float64
+		wantErr bool
+	}{
+		{name: "Enabled", enabled: true, rate: 0.01},
+		{name: "Enabled zero rate", enabled: true, rate: 0, wantErr: true},
+		{name: "Enabled rate of one", enabled: true, rate: 1, wantErr: true},
+		{name: "Disabled zero rate", rate: 0},
+		{name: "Disabled rate above one", rate: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.Bloom.Enabled, c.Bloom.FalsePositiveRate = tt.enabled, tt.rate
+			err := c.Validate()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "bloom.false_positive_rate")
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
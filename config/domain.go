@@ -0,0 +1,68 @@
+package config
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DefaultDomainID - id of the single domain served at BaseURL when no domains are configured
+const DefaultDomainID = "default"
+
+// DomainConfig - short link domain
+type DomainConfig struct {
+	// ID - stored with each link, must not change while links of the domain exist
+	ID string `json:"id"`
+	// Host - request Host served by domain, empty means host of BaseURL
+	Host    string `json:"host"`
+	BaseURL string `json:"base_url"`
+	// DefaultRedirect - destination for unknown codes, empty answers 410 Gone
+	DefaultRedirect string `json:"default_redirect"`
+}
+
+// DomainList - configured domains with hosts filled in, first one is the default.
+// Without configured domains returns DefaultDomainID served at BaseURL.
+func (c Config) DomainList() []DomainConfig {
+	ds := c.Domains
+	if len(ds) == 0 {
+		ds = []DomainConfig{{ID: DefaultDomainID, BaseURL: c.BaseURL}}
+	}
+	res := make([]DomainConfig, len(ds))
+	for i, d := range ds {
+		if d.Host == "" {
+			if u, err := url.Parse(d.BaseURL); err == nil {
+				d.Host = u.Host
+			}
+		}
+		res[i] = d
+	}
+	return res
+}
+
+// Domain - domain by id
+func (c Config) Domain(id string) (DomainConfig, bool) {
+	for _, d := range c.DomainList() {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return DomainConfig{}, false
+}
+
+// DomainByHost - domain serving request host, host may carry port.
+// Domain host without port matches any port. Unknown hosts get the default domain.
+func (c Config) DomainByHost(host string) DomainConfig {
+	ds := c.DomainList()
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	for _, d := range ds {
+		dh := strings.ToLower(d.Host)
+		if dh == host || dh == name {
+			return d
+		}
+	}
+	return ds[0]
+}
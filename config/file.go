@@ -0,0 +1,94 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// decodeFile reads config file in json, yaml or toml format selected by extension.
+// Yaml and toml are converted to json so all formats share json field names and value parsing.
+func decodeFile(name string, c *Config) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+	case ".yaml", ".yml":
+		var m map[string]interface{}
+		if err = yaml.Unmarshal(b, &m); err != nil {
+			return fmt.Errorf("config file %s: %w", name, err)
+		}
+		if b, err = json.Marshal(m); err != nil {
+			return fmt.Errorf("config file %s: %w", name, err)
+		}
+	case ".toml":
+		var m map[string]interface{}
+		if _, err = toml.Decode(string(b), &m); err != nil {
+			return fmt.Errorf("config file %s: %w", name, err)
+		}
+		if b, err = json.Marshal(m); err != nil {
+			return fmt.Errorf("config file %s: %w", name, err)
+		}
+	default:
+		return fmt.Errorf("config file %s: unknown format, use .json, .yaml, .yml or .toml", name)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("config file %s: %w", name, err)
+	}
+	return nil
+}
+
+const redacted = "REDACTED"
+
+var dsnPassword = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+
+// Redacted - returns copy of config with secrets replaced
+func (c Config) Redacted() Config {
+	if c.AdminToken != "" {
+		c.AdminToken = redacted
+	}
+	if c.Safety.APIKey != "" {
+		c.Safety.APIKey = redacted
+	}
+	c.DatabaseDsn = redactDSN(c.DatabaseDsn)
+	if c.Replicas.DSNs != nil {
+		dsns := make([]string, len(c.Replicas.DSNs))
+		for i, dsn := range c.Replicas.DSNs {
+			dsns[i] = redactDSN(dsn)
+		}
+		c.Replicas.DSNs = dsns
+	}
+	return c
+}
+
+// redactDSN - dsn in url or key=value form with password replaced
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+		return dsn
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redacted)
+}
+
+// Print - writes config with secrets redacted as indented json
+func Print(w io.Writer, c Config) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.Redacted())
+}
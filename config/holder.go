@@ -0,0 +1,161 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Subscriber - called after config change with previous and new config
+type Subscriber func(old, new Config)
+
+// Holder - current config shared by components, safe for concurrent use and reloadable
+type Holder struct {
+	mu sync.RWMutex
+	c  Config
+
+	// args, path - command line config was loaded from, set by Init
+	args   []string
+	path   string
+	loaded bool
+
+	reloadMu sync.Mutex
+	subsMu   sync.Mutex
+	subsNext int
+	subs     map[int]Subscriber
+}
+
+// NewHolder - constructor
+func NewHolder(c Config) *Holder {
+	return &Holder{c: c, subs: make(map[int]Subscriber)}
+}
+
+// Get - returns copy of current config
+func (h *Holder) Get() Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.c
+}
+
+// Path - returns name of config file given by -c flag
+func (h *Holder) Path() string {
+	return h.path
+}
+
+// Subscribe - registers f to be notified about config changes, returned func unregisters it
+func (h *Holder) Subscribe(f Subscriber) (unsubscribe func()) {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	id := h.subsNext
+	h.subsNext++
+	h.subs[id] = f
+	return func() {
+		h.subsMu.Lock()
+		defer h.subsMu.Unlock()
+		delete(h.subs, id)
+	}
+}
+
+// Update - replaces config and notifies subscribers when it changed
+func (h *Holder) Update(c Config) {
+	h.mu.Lock()
+	old := h.c
+	h.c = c
+	h.mu.Unlock()
+	if reflect.DeepEqual(old, c) {
+		return
+	}
+
+	h.subsMu.Lock()
+	fs := make([]Subscriber, 0, len(h.subs))
+	for _, f := range h.subs {
+		fs = append(fs, f)
+	}
+	h.subsMu.Unlock()
+	for _, f := range fs {
+		f(old, c)
+	}
+}
+
+// Reload - rereads config file, flags and environment and applies settings safe to change at runtime.
+// Changes of settings tagged reload:"restart" are not applied, their json names are returned in refused.
+// On error current config is left untouched.
+func (h *Holder) Reload() (refused []string, err error) {
+	if !h.loaded {
+		return nil, errors.New("config was not loaded from command line")
+	}
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	old := h.Get()
+	c, _, err := load(h.args, flag.ContinueOnError)
+	if err != nil {
+		return nil, err
+	}
+	refused = keepRestartOnly(old, &c)
+	h.Update(c)
+	return refused, nil
+}
+
+// keepRestartOnly copies fields tagged reload:"restart" from old to c and returns json names of changed ones
+func keepRestartOnly(old Config, c *Config) (changed []string) {
+	return keepRestartFields(reflect.ValueOf(old), reflect.ValueOf(c).Elem(), "")
+}
+
+// keepRestartFields - keepRestartOnly of struct values, untagged struct fields are checked field by field
+func keepRestartFields(ov, cv reflect.Value, prefix string) (changed []string) {
+	for i := 0; i < ov.NumField(); i++ {
+		f := ov.Type().Field(i)
+		name := prefix + strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Tag.Get("reload") != "restart" {
+			if f.Type.Kind() == reflect.Struct {
+				changed = append(changed, keepRestartFields(ov.Field(i), cv.Field(i), name+".")...)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(ov.Field(i).Interface(), cv.Field(i).Interface()) {
+			changed = append(changed, name)
+			cv.Field(i).Set(ov.Field(i))
+		}
+	}
+	return changed
+}
+
+// Watch - checks config file every interval and sends to returned channel when its modification time changes
+func (h *Holder) Watch(ctx context.Context, interval time.Duration) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	p := h.Path()
+	if p == "" || interval <= 0 {
+		return ch
+	}
+	var modTime time.Time
+	if st, err := os.Stat(p); err == nil {
+		modTime = st.ModTime()
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+			st, err := os.Stat(p)
+			if err != nil || st.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = st.ModTime()
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch
+}
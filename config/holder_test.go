@@ -0,0 +1,63 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHolder_Reload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	write := func(s string) {
+		require.NoError(t, os.WriteFile(file, []byte(s), 0644))
+	}
+	write(`{"base_url":"http://a.test/","database_dsn":"postgres://a"}`)
+
+	args := []string{"-c", file, "-l", "debug"}
+	c, o, err := load(args, flag.ContinueOnError)
+	require.NoError(t, err)
+	h := NewHolder(c)
+	_, err = h.Reload()
+	assert.Error(t, err, "holder not created by Init")
+	h.args, h.path, h.loaded = args, o.path, true
+
+	var notified []Config
+	unsubscribe := h.Subscribe(func(old, new Config) { notified = append(notified, old, new) })
+	defer unsubscribe()
+
+	refused, err := h.Reload()
+	require.NoError(t, err)
+	assert.Empty(t, refused)
+	assert.Empty(t, notified)
+
+	write(`{"base_url":"http://b.test/","database_dsn":"postgres://b","log_level":"error","admin_token":"t",` +
+		`"tls":{"cert_file":"b.crt","key_file":"b.key","min_version":"1.3"}}`)
+	refused, err = h.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"database_dsn", "tls.min_version"}, refused)
+	got := h.Get()
+	assert.Equal(t, "http://b.test/", got.BaseURL)
+	assert.Equal(t, "t", got.AdminToken)
+	assert.Equal(t, "postgres://a", got.DatabaseDsn)
+	assert.Equal(t, "b.crt", got.TLS.CertFile, "certificate paths are reloadable")
+	assert.Equal(t, "1.2", got.TLS.MinVersion)
+	assert.Equal(t, "debug", got.LogLevel, "flag wins over file")
+	require.Len(t, notified, 2)
+	assert.Equal(t, "http://a.test/", notified[0].BaseURL)
+	assert.Equal(t, "http://b.test/", notified[1].BaseURL)
+
+	write(`{"base_url":`)
+	_, err = h.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "http://b.test/", h.Get().BaseURL)
+
+	unsubscribe()
+	write(`{"base_url":"http://c.test/"}`)
+	_, err = h.Reload()
+	require.NoError(t, err)
+	assert.Len(t, notified, 2)
+}
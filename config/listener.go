@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ListenerConfig - socket serving the shortener router
+type ListenerConfig struct {
+	// Network - "tcp", "unix" or "systemd" for socket passed by service manager
+	Network string `json:"network"`
+	// Address - host:port for tcp, socket path for unix,
+	// LISTEN_FDNAMES name or zero-based index of passed socket for systemd
+	Address string `json:"address"`
+	// Mode - octal permissions of unix socket file, empty keeps umask default
+	Mode string `json:"mode"`
+	// TLS - serve https with tls settings
+	TLS bool `json:"tls"`
+	// H2C - accept cleartext HTTP/2, for sidecar proxies; not allowed with tls
+	H2C bool `json:"h2c"`
+}
+
+// ListenerList - configured listeners.
+// Without configured listeners returns tcp listener at ServerAddress, https when enable_https is set.
+func (c Config) ListenerList() []ListenerConfig {
+	if len(c.Listeners) > 0 {
+		return c.Listeners
+	}
+	return []ListenerConfig{{Network: "tcp", Address: c.ServerAddress, TLS: c.SecureConnection}}
+}
+
+// ServesTLS - whether any listener serves https
+func (c Config) ServesTLS() bool {
+	for _, l := range c.ListenerList() {
+		if l.TLS {
+			return true
+		}
+	}
+	return false
+}
+
+// SocketMode - parsed Mode, zero when not set
+func (l ListenerConfig) SocketMode() (os.FileMode, error) {
+	if l.Mode == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(l.Mode, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("%q is not octal permission bits", l.Mode)
+	}
+	return os.FileMode(m), nil
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration - time.Duration written in config as "1m30s" or as number of seconds
+type Duration time.Duration
+
+// UnmarshalText - parses "1m30s" or plain number of seconds
+func (d *Duration) UnmarshalText(b []byte) error {
+	s := strings.TrimSpace(string(b))
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		*d = Duration(f * float64(time.Second))
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// UnmarshalJSON - accepts json number of seconds or string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		return d.UnmarshalText([]byte(s))
+	}
+	return d.UnmarshalText(b)
+}
+
+// MarshalText - formats duration as "1m30s"
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Size - number of bytes written in config as "100MB" or plain number of bytes.
+// Units K, M, G with optional B or iB suffix are 1024 based.
+type Size int64
+
+var sizeUnits = []struct {
+	suffix string
+	mult   int64
+}{
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"", 1},
+}
+
+// UnmarshalText - parses "100MB", "64KiB" or plain number of bytes
+func (s *Size) UnmarshalText(b []byte) error {
+	v := strings.ToUpper(strings.TrimSpace(string(b)))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "B"), "I")
+	for _, u := range sizeUnits {
+		if !strings.HasSuffix(v, u.suffix) {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(v, u.suffix)), 10, 64)
+		if err != nil {
+			break
+		}
+		*s = Size(n * u.mult)
+		return nil
+	}
+	return fmt.Errorf("invalid size %q", string(b))
+}
+
+// UnmarshalJSON - accepts json number of bytes or string
+func (s *Size) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		return s.UnmarshalText([]byte(str))
+	}
+	return s.UnmarshalText(b)
+}
+
+// MarshalText - formats size with largest unit dividing it exactly
+func (s Size) MarshalText() ([]byte, error) {
+	for _, u := range sizeUnits {
+		if s != 0 && int64(s)%u.mult == 0 {
+			return []byte(strconv.FormatInt(int64(s)/u.mult, 10) + u.suffix + "B"), nil
+		}
+	}
+	return []byte("0B"), nil
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// FieldError - invalid value of config setting named by json path
+type FieldError struct {
+	Field string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+// Validate - checks all settings and returns every problem found joined in one error
+func (c Config) Validate() error {
+	var errs []error
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+	oneOf := func(field, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		add(field, "%q is not one of %s", value, strings.Join(allowed, ", "))
+	}
+
+	if err := validAddress(c.ServerAddress); err != nil {
+		add("server_address", "%v", err)
+	}
+	if c.AdminAddress != "" {
+		if err := validAddress(c.AdminAddress); err != nil {
+			add("admin_address", "%v", err)
+		}
+	}
+	for i, l := range c.Listeners {
+		field := "listeners[" + strconv.Itoa(i) + "]"
+		oneOf(field+".network", l.Network, "tcp", "unix", "systemd")
+		switch {
+		case l.Address == "":
+			add(field+".address", "required")
+		case l.Network == "tcp":
+			if err := validAddress(l.Address); err != nil {
+				add(field+".address", "%v", err)
+			}
+		}
+		if _, err := l.SocketMode(); err != nil {
+			add(field+".mode", "%v", err)
+		} else if l.Mode != "" && l.Network != "unix" {
+			add(field+".mode", "only unix sockets have mode")
+		}
+		if l.TLS && l.H2C {
+			add(field, "h2c is cleartext, tls negotiates HTTP/2 itself")
+		}
+	}
+	if err := validLinkBase(c.BaseURL); err != nil {
+		add("base_url", "%v", err)
+	}
+	ids, hosts := make(map[string]bool), make(map[string]bool)
+	for i, d := range c.Domains {
+		field := "domains[" + strconv.Itoa(i) + "]"
+		switch {
+		case d.ID == "":
+			add(field+".id", "required")
+		case strings.ContainsAny(d.ID, "/ "):
+			add(field+".id", "must not contain slash or space")
+		case ids[d.ID]:
+			add(field+".id", "duplicate %q", d.ID)
+		}
+		ids[d.ID] = true
+		if err := validLinkBase(d.BaseURL); err != nil {
+			add(field+".base_url", "%v", err)
+		}
+		if d.DefaultRedirect != "" {
+			if err := validBaseURL(d.DefaultRedirect); err != nil {
+				add(field+".default_redirect", "%v", err)
+			}
+		}
+	}
+	if len(c.Domains) > 0 {
+		for i, d := range c.DomainList() {
+			h := strings.ToLower(d.Host)
+			if hosts[h] {
+				add("domains["+strconv.Itoa(i)+"].host", "duplicate %q", d.Host)
+			}
+			hosts[h] = true
+		}
+	}
+
+	oneOf("log_level", strings.ToLower(c.LogLevel), "trace", "debug", "info", "warn", "warning", "error", "fatal", "panic")
+	oneOf("log_backend", c.LogBackend, "logrus", "slog")
+	oneOf("log_format", c.LogFormat, "text", "json")
+	oneOf("log_output", c.LogOutput, "stderr", "stdout", "file", "syslog")
+	if c.LogOutput == "file" && c.LogFile.Path == "" {
+		add("log_file.path", "required when log_output is file")
+	}
+	if c.LogFile.MaxSize < 0 {
+		add("log_file.max_size", "must not be negative")
+	}
+	if c.LogFile.MaxAge < 0 {
+		add("log_file.max_age", "must not be negative")
+	}
+	if c.LogFile.MaxBackups < 0 {
+		add("log_file.max_backups", "must not be negative")
+	}
+
+	if len(c.URL.AllowedSchemes) == 0 {
+		add("url.allowed_schemes", "at least one scheme is required")
+	}
+	if c.URL.MaxLength < 0 {
+		add("url.max_length", "must not be negative")
+	}
+	if c.URL.LookupTimeout < 0 {
+		add("url.lookup_timeout", "must not be negative")
+	}
+	for i, e := range c.Compress.Encodings {
+		oneOf("compress.encodings["+strconv.Itoa(i)+"]", e, "br", "zstd", "gzip", "deflate")
+	}
+	if c.Compress.MinSize < 0 || c.Compress.MaxRequestSize < 0 || c.Compress.MaxDecodedSize < 0 {
+		add("compress", "sizes must not be negative")
+	}
+	if c.BlocklistReloadInterval < 0 {
+		add("blocklist_reload_interval", "must not be negative")
+	}
+	if c.ConfigWatchInterval < 0 {
+		add("config_watch_interval", "must not be negative")
+	}
+	if s := c.Shutdown; s.ReadinessDelay < 0 || s.DrainTimeout < 0 || s.AsyncTimeout < 0 || s.CloseTimeout < 0 {
+		add("shutdown", "durations must not be negative")
+	}
+	if c.Health.Timeout < 0 || c.Health.CacheTTL < 0 {
+		add("health", "durations must not be negative")
+	}
+	if c.Cache.Size < 0 {
+		add("cache.size", "must not be negative")
+	}
+	if c.Cache.TTL < 0 || c.Cache.NegativeTTL < 0 || c.Cache.FallbackTTL < 0 {
+		add("cache", "durations must not be negative")
+	}
+	if c.Bloom.Enabled && (c.Bloom.FalsePositiveRate <= 0 || c.Bloom.FalsePositiveRate >= 1) {
+		add("bloom.false_positive_rate", "must be between 0 and 1")
+	}
+	if c.Bloom.Capacity < 0 {
+		add("bloom.capacity", "must not be negative")
+	}
+	if c.Bloom.RebuildInterval < 0 {
+		add("bloom.rebuild_interval", "must not be negative")
+	}
+	if len(c.Replicas.DSNs) > 0 {
+		if c.DatabaseDsn == "" {
+			add("replicas.dsns", "requires database_dsn")
+		}
+		for i, dsn := range c.Replicas.DSNs {
+			if dsn == "" {
+				add("replicas.dsns["+strconv.Itoa(i)+"]", "required")
+			}
+		}
+		if c.Replicas.CheckInterval <= 0 || c.Replicas.CheckTimeout <= 0 {
+			add("replicas", "check_interval and check_timeout must be positive")
+		}
+	}
+	if c.Replicas.MaxLag < 0 || c.Replicas.ReadYourWrites < 0 {
+		add("replicas", "durations must not be negative")
+	}
+	if c.Health.MaxDeleteBacklog < 0 {
+		add("health.max_delete_backlog", "must not be negative")
+	}
+
+	if c.ServesTLS() {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			add("tls", "cert_file and key_file are required when https is served")
+		}
+		if c.TLS.RedirectAddress != "" {
+			if err := validAddress(c.TLS.RedirectAddress); err != nil {
+				add("tls.redirect_address", "%v", err)
+			}
+		}
+	}
+	oneOf("tls.min_version", c.TLS.MinVersion, "1.2", "1.3")
+	for i, name := range c.TLS.CipherSuites {
+		if _, err := CipherSuite(name); err != nil {
+			add("tls.cipher_suites["+strconv.Itoa(i)+"]", "%v", err)
+		}
+	}
+	for i, route := range c.TLS.ClientCertRoutes {
+		if !strings.HasPrefix(route, "/") || strings.Contains(strings.TrimSuffix(route, "/*"), "*") {
+			add("tls.client_cert_routes["+strconv.Itoa(i)+"]", "%q must be absolute path, optionally ending with /*", route)
+		}
+	}
+	if c.TLS.ReloadInterval < 0 || c.TLS.HSTSMaxAge < 0 {
+		add("tls", "durations must not be negative")
+	}
+
+	oneOf("safety.provider", c.Safety.Provider, "", "http", "local")
+	if c.Safety.Provider == "http" && c.Safety.Endpoint == "" {
+		add("safety.endpoint", "required for http provider")
+	}
+	if c.Safety.Provider == "local" && c.Safety.LocalPath == "" {
+		add("safety.local_path", "required for local provider")
+	}
+	if c.Safety.CacheSize < 0 {
+		add("safety.cache_size", "must not be negative")
+	}
+	if c.Safety.Timeout < 0 || c.Safety.CacheTTL < 0 || c.Safety.RescanInterval < 0 {
+		add("safety", "durations must not be negative")
+	}
+
+	oneOf("tracing.exporter", c.Tracing.Exporter, "", "otlp", "stdout")
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio", "must be between 0 and 1")
+	}
+	return errors.Join(errs...)
+}
+
+// CipherSuite - id of secure cipher suite by crypto/tls name
+func CipherSuite(name string) (uint16, error) {
+	for _, s := range tls.CipherSuites() {
+		if s.Name == name {
+			return s.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown or insecure cipher suite %q", name)
+}
+
+// TLSVersion - crypto/tls version constant for min_version value
+func TLSVersion(v string) uint16 {
+	if v == "1.3" {
+		return tls.VersionTLS13
+	}
+	return tls.VersionTLS12
+}
+
+// validBaseURL checks absolute http(s) url
+func validBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	switch {
+	case err != nil:
+		return err
+	case u.Scheme != "http" && u.Scheme != "https":
+		return errors.New("scheme must be http or https")
+	case u.Host == "":
+		return errors.New("host is required")
+	}
+	return nil
+}
+
+// validLinkBase checks base url of short links, its path must not shadow api routes
+func validLinkBase(raw string) error {
+	if err := validBaseURL(raw); err != nil {
+		return err
+	}
+	if u, _ := url.Parse(raw); u.Path == "/api" || strings.HasPrefix(u.Path, "/api/") {
+		return errors.New("path must not be under /api/")
+	}
+	return nil
+}
+
+// validAddress checks host:port listen address
+func validAddress(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 0 || p > 65535 {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
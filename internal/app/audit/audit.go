@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/app/model"
+)
+
+// ErrBroken - chain verification failed
+var ErrBroken = errors.New("audit chain broken")
+
+// Seal - links event e with id to previous event hash and computes its own hash.
+// Time is truncated to microseconds so event survives round trip through postgres unchanged.
+func Seal(e model.AuditEvent, id int64, prevHash string) model.AuditEvent {
+	e.ID = id
+	e.Time = e.Time.UTC().Truncate(time.Microsecond)
+	e.PrevHash = prevHash
+	e.Hash = Hash(e)
+	return e
+}
+
+// Hash - hash of event fields and previous hash, Hash field itself is ignored.
+// Domain and states are hashed only when set so events written before they existed still verify.
+func Hash(e model.AuditEvent) string {
+	code := e.Code
+	if e.Domain != "" {
+		code = e.Domain + "/" + e.Code
+	}
+	fields := []string{
+		strconv.FormatInt(e.ID, 10),
+		e.Time.UTC().Format(time.RFC3339Nano),
+		e.Actor,
+		e.Action,
+		code,
+		e.OldURL,
+		e.NewURL,
+		e.Reason,
+		e.RemoteAddr,
+		e.RequestID,
+		e.PrevHash,
+	}
+	if e.OldState != "" || e.NewState != "" {
+		fields = append(fields, e.OldState, e.NewState)
+	}
+	h := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return hex.EncodeToString(h[:])
+}
+
+// Verify - checks that events form unbroken chain starting from the first event
+func Verify(events []model.AuditEvent) error {
+	prev := ""
+	for i, e := range events {
+		if i > 0 && e.ID != events[i-1].ID+1 {
+			return fmt.Errorf("%w: event %d follows %d", ErrBroken, e.ID, events[i-1].ID)
+		}
+		if e.PrevHash != prev {
+			return fmt.Errorf("%w: event %d prev hash mismatch", ErrBroken, e.ID)
+		}
+		if Hash(e) != e.Hash {
+			return fmt.Errorf("%w: event %d hash mismatch", ErrBroken, e.ID)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func chain(n int) []model.AuditEvent {
+	var events []model.AuditEvent
+	prev := ""
+	for i := 1; i <= n; i++ {
+		e := Seal(model.AuditEvent{
+			Time:   time.Date(2024, 1, 1, 0, 0, i, 1234567, time.FixedZone("X", 3600)),
+			Actor:  "user",
+			Action: model.ActionCreate,
+			Code:   "a7930003",
+			NewURL: "https://go.dev/",
+		}, int64(i), prev)
+		if i == n {
+			e = Seal(model.AuditEvent{
+				Time:     e.Time,
+				Actor:    model.ActorSystem,
+				Action:   model.ActionQuarantine,
+				Code:     "a7930003",
+				OldState: model.StateActive,
+				NewState: model.StateQuarantined,
+			}, int64(i), prev)
+		}
+		prev = e.Hash
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(events []model.AuditEvent) []model.AuditEvent
+		wantErr bool
+	}{
+		{name: "Intact", tamper: func(e []model.AuditEvent) []model.AuditEvent { return e }},
+		{name: "Empty", tamper: func(e []model.AuditEvent) []model.AuditEvent { return nil }},
+		{name: "Field modified", tamper: func(e []model.AuditEvent) []model.AuditEvent {
+			e[1].NewURL = "https://evil.test/"
+			return e
+		}, wantErr: true},
+		{name: "Event removed", tamper: func(e []model.AuditEvent) []model.AuditEvent {
+			return append(e[:1], e[2:]...)
+		}, wantErr: true},
+		{name: "State modified", tamper: func(e []model.AuditEvent) []model.AuditEvent {
+			e[2].NewState = model.StateActive
+			return e
+		}, wantErr: true},
+		{name: "Rehashed after modify", tamper: func(e []model.AuditEvent) []model.AuditEvent {
+			e[1].Actor = "admin"
+			e[1].Hash = Hash(e[1])
+			return e
+		}, wantErr: true},
+		{name: "First removed", tamper: func(e []model.AuditEvent) []model.AuditEvent { return e[1:] }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.tamper(chain(3)))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrBroken)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSeal_TimeRoundTrip(t *testing.T) {
+	e := chain(1)[0]
+	assert.Equal(t, time.UTC, e.Time.Location())
+	e.Time = e.Time.In(time.Local)
+	assert.Equal(t, e.Hash, Hash(e))
+}
@@ -0,0 +1,2 @@
+// Package audit - hash chaining of link lifecycle events for tamper evidence
+package audit
@@ -0,0 +1,188 @@
+package blocklist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/logger"
+)
+
+// rules - parsed blocklist
+type rules struct {
+	exact  map[string]struct{}
+	suffix []string
+	regex  []*regexp.Regexp
+}
+
+// List struct
+type List struct {
+	// loadMu serializes loads, path and modTime are written only under both locks
+	loadMu  sync.Mutex
+	mu      sync.RWMutex
+	rules   rules
+	path    string
+	modTime time.Time
+	logger  logger.Logger
+}
+
+// New - constructor, loads rules from path and reloads them every interval when file changes.
+// Empty path gives empty list, zero interval disables reloading.
+func New(ctx context.Context, l logger.Logger, path string, interval time.Duration) (*List, error) {
+	bl := &List{path: path, logger: l}
+	if interval > 0 {
+		go bl.watch(ctx, interval)
+	}
+	if path == "" {
+		return bl, nil
+	}
+	return bl, bl.Reload()
+}
+
+// Parse - parses rules from r.
+// One rule per line: "example.com" - exact host, "*.example.com" - host and its subdomains,
+// "/regexp/" - regular expression matched against host. Lines starting with "#" are comments.
+func Parse(r io.Reader) (*List, error) {
+	rs, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return &List{rules: rs}, nil
+}
+
+func parse(r io.Reader) (rules, error) {
+	rs := rules{exact: make(map[string]struct{})}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case len(line) > 1 && strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/"):
+			re, err := regexp.Compile(line[1 : len(line)-1])
+			if err != nil {
+				return rules{}, fmt.Errorf("line %d: %w", n, err)
+			}
+			rs.regex = append(rs.regex, re)
+		case strings.HasPrefix(line, "*."):
+			rs.suffix = append(rs.suffix, strings.ToLower(line[1:]))
+		default:
+			rs.exact[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return rs, sc.Err()
+}
+
+// SetPath - switches list to rules from path, empty path clears rules.
+// On error list keeps previous rules and path.
+func (bl *List) SetPath(path string) error {
+	bl.loadMu.Lock()
+	defer bl.loadMu.Unlock()
+	if path == "" {
+		bl.mu.Lock()
+		bl.rules, bl.path, bl.modTime = rules{}, "", time.Time{}
+		bl.mu.Unlock()
+		return nil
+	}
+	return bl.load(path)
+}
+
+// Reload - rereads rules from file
+func (bl *List) Reload() error {
+	bl.loadMu.Lock()
+	defer bl.loadMu.Unlock()
+	return bl.load(bl.path)
+}
+
+func (bl *List) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	rs, err := parse(f)
+	if err != nil {
+		return err
+	}
+	bl.mu.Lock()
+	bl.rules = rs
+	bl.path = path
+	bl.modTime = st.ModTime()
+	bl.mu.Unlock()
+	return nil
+}
+
+func (bl *List) watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		bl.reloadChanged()
+	}
+}
+
+// reloadChanged reloads rules when file modification time differs from loaded one
+func (bl *List) reloadChanged() {
+	bl.loadMu.Lock()
+	defer bl.loadMu.Unlock()
+	if bl.path == "" {
+		return
+	}
+	st, err := os.Stat(bl.path)
+	if err != nil {
+		bl.logger.WithField("error", err).Warn("Error while stat blocklist")
+		return
+	}
+	if st.ModTime().Equal(bl.modTime) {
+		return
+	}
+	if err = bl.load(bl.path); err != nil {
+		bl.logger.WithField("error", err).Warn("Error while reload blocklist")
+		return
+	}
+	bl.logger.WithField("path", bl.path).Info("Blocklist reloaded")
+}
+
+// Blocked - checks host against rules
+func (bl *List) Blocked(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	bl.mu.RLock()
+	defer bl.mu.RUnlock()
+	if _, ok := bl.rules.exact[host]; ok {
+		return true
+	}
+	for _, s := range bl.rules.suffix {
+		if strings.HasSuffix(host, s) || host == s[1:] {
+			return true
+		}
+	}
+	for _, re := range bl.rules.regex {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockedURL - checks host of raw url against rules
+func (bl *List) BlockedURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return bl.Blocked(u.Hostname())
+}
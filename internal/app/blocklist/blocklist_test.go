@@ -0,0 +1,87 @@
+package blocklist
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestList_Blocked(t *testing.T) {
+	bl, err := Parse(strings.NewReader(`
+# comment
+evil.com
+*.phish.net
+/^login-.*\.example\.org$/
+`))
+	require.NoError(t, err)
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "evil.com", want: true},
+		{host: "EVIL.com.", want: true},
+		{host: "sub.evil.com", want: false},
+		{host: "phish.net", want: true},
+		{host: "a.b.phish.net", want: true},
+		{host: "notphish.net", want: false},
+		{host: "login-bank.example.org", want: true},
+		{host: "example.org", want: false},
+		{host: "go.dev", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			assert.Equal(t, tt.want, bl.Blocked(tt.host))
+		})
+	}
+	assert.True(t, bl.BlockedURL("https://evil.com/login"))
+	assert.False(t, bl.BlockedURL("https://go.dev/"))
+}
+
+func TestParse_BadRegexp(t *testing.T) {
+	_, err := Parse(strings.NewReader("/[/"))
+	assert.Error(t, err)
+}
+
+func TestList_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("evil.com\n"), 0644))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bl, err := New(ctx, logger.NewDummy(), path, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, bl.Blocked("evil.com"))
+	assert.False(t, bl.Blocked("bad.org"))
+
+	require.NoError(t, os.WriteFile(path, []byte("bad.org\n"), 0644))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+	assert.Eventually(t, func() bool {
+		return bl.Blocked("bad.org") && !bl.Blocked("evil.com")
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestList_SetPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("evil.com\n"), 0644))
+
+	bl, err := New(context.Background(), logger.NewDummy(), "", 0)
+	require.NoError(t, err)
+	assert.False(t, bl.Blocked("evil.com"))
+
+	require.NoError(t, bl.SetPath(path))
+	assert.True(t, bl.Blocked("evil.com"))
+
+	assert.Error(t, bl.SetPath(filepath.Join(dir, "missing.txt")))
+	assert.True(t, bl.Blocked("evil.com"))
+
+	require.NoError(t, bl.SetPath(""))
+	assert.False(t, bl.Blocked("evil.com"))
+}
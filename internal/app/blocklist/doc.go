@@ -0,0 +1,2 @@
+// Package blocklist - destination host blocklist with hot reload
+package blocklist
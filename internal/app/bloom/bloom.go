@@ -0,0 +1,86 @@
+// Package bloom - Bloom filter of strings: answers "definitely absent" or "maybe present" in fixed memory
+package bloom
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+)
+
+// Filter - Bloom filter, safe for concurrent use
+type Filter struct {
+	mu    sync.RWMutex
+	bits  []uint64
+	m     uint64
+	k     uint64
+	count uint64
+	seed1 maphash.Seed
+	seed2 maphash.Seed
+}
+
+// New - filter sized for n strings with false positive rate p
+func New(n int, p float64) *Filter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &Filter{
+		bits:  make([]uint64, m/64),
+		m:     m,
+		k:     k,
+		seed1: maphash.MakeSeed(),
+		seed2: maphash.MakeSeed(),
+	}
+}
+
+// positions - double hashing, i-th bit is h1 + i*h2
+func (f *Filter) positions(s string) (h1, h2 uint64) {
+	return maphash.String(f.seed1, s), maphash.String(f.seed2, s) | 1
+}
+
+// Add - adds s
+func (f *Filter) Add(s string) {
+	h1, h2 := f.positions(s)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := uint64(0); i < f.k; i++ {
+		b := (h1 + i*h2) % f.m
+		f.bits[b/64] |= 1 << (b % 64)
+	}
+	f.count++
+}
+
+// MayContain - false when s was never added
+func (f *Filter) MayContain(s string) bool {
+	h1, h2 := f.positions(s)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i := uint64(0); i < f.k; i++ {
+		b := (h1 + i*h2) % f.m
+		if f.bits[b/64]&(1<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count - number of Add calls
+func (f *Filter) Count() int {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return int(f.count)
+}
+
+// Bytes - memory taken by bit array
+func (f *Filter) Bytes() int {
+	return len(f.bits) * 8
+}
+
+// FalsePositiveRate - expected rate at current count
+func (f *Filter) FalsePositiveRate() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return math.Pow(1-math.Exp(-float64(f.k*f.count)/float64(f.m)), float64(f.k))
+}
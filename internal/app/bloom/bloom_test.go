@@ -0,0 +1,32 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	const n = 10000
+	f := New(n, 0.01)
+	assert.Equal(t, 0, f.Count())
+	assert.False(t, f.MayContain("a7930003"))
+	for i := 0; i < n; i++ {
+		f.Add("in/" + strconv.Itoa(i))
+	}
+	for i := 0; i < n; i++ {
+		assert.True(t, f.MayContain("in/"+strconv.Itoa(i)), "no false negatives")
+	}
+	fp := 0
+	for i := 0; i < n; i++ {
+		if f.MayContain("out/" + strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	assert.Less(t, float64(fp)/n, 0.02)
+	assert.InDelta(t, 0.01, f.FalsePositiveRate(), 0.005)
+	assert.Equal(t, n, f.Count())
+	// about 1.2 bytes per string at 1%
+	assert.InDelta(t, 12000, f.Bytes(), 500)
+}
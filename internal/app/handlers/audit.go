@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Stas9132/shortener/internal/app/audit"
+	"github.com/Stas9132/shortener/internal/app/handlers/middleware"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+// auditEvent - event of action on link performed by request issuer
+func auditEvent(r *http.Request, action string, key model.Key) model.AuditEvent {
+	return model.AuditEvent{
+		Time:       time.Now(),
+		Actor:      middleware.GetIssuer(r.Context()).ID,
+		Action:     action,
+		Domain:     key.Domain,
+		Code:       key.Code,
+		RemoteAddr: r.RemoteAddr,
+		RequestID:  middleware.GetRequestID(r.Context()),
+	}
+}
+
+// addAudit stores event, failure is logged and does not fail the request
+func (a APIT) addAudit(ctx context.Context, e model.AuditEvent) {
+	if err := a.storage.AddAuditEvent(ctx, e); err != nil {
+		a.log(ctx).WithFields(map[string]interface{}{
+			"action": e.Action,
+			"domain": e.Domain,
+			"code":   e.Code,
+			"error":  err,
+		}).Error("Error while add audit event")
+	}
+}
+
+// auditCreate records creation of link pointing to originalURL
+func (a APIT) auditCreate(r *http.Request, key model.Key, originalURL string) {
+	e := auditEvent(r, model.ActionCreate, key)
+	e.NewURL = originalURL
+	a.addAudit(r.Context(), e)
+}
+
+// GetAudit - admin api handler, query parameters user, domain, code, from and to (RFC 3339) filter events
+func (a APIT) GetAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := model.AuditFilter{Actor: q.Get("user"), Domain: q.Get("domain"), Code: q.Get("code")}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, p.name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*p.t = t
+		}
+	}
+
+	events, err := a.storage.AuditEvents(r.Context(), filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(events) == 0 {
+		render.NoContent(w, r)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, events)
+}
+
+// GetAuditVerify - admin api handler, checks hash chain of whole audit log
+func (a APIT) GetAuditVerify(w http.ResponseWriter, r *http.Request) {
+	events, err := a.storage.AuditEvents(r.Context(), model.AuditFilter{})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err = audit.Verify(events); err != nil {
+		a.log(r.Context()).WithField("error", err).Error("Audit log verification failed")
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, map[string]interface{}{"events": len(events)})
+}
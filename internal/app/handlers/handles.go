@@ -5,13 +5,23 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/app/blocklist"
 	"github.com/Stas9132/shortener/internal/app/handlers/middleware"
 	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/app/safety"
+	strg "github.com/Stas9132/shortener/internal/app/storage"
+	"github.com/Stas9132/shortener/internal/app/urlnorm"
 	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/Stas9132/shortener/internal/metrics"
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -27,30 +37,93 @@ type APII interface {
 	GetPing(w http.ResponseWriter, r *http.Request)
 	PostBatch(w http.ResponseWriter, r *http.Request)
 	DeleteUserUrls(w http.ResponseWriter, r *http.Request)
+	PostReport(w http.ResponseWriter, r *http.Request)
+	GetReports(w http.ResponseWriter, r *http.Request)
+	PostDisable(w http.ResponseWriter, r *http.Request)
+	GetAudit(w http.ResponseWriter, r *http.Request)
+	GetAuditVerify(w http.ResponseWriter, r *http.Request)
 }
 
 // StorageI - interface to storage
-type StorageI interface {
-	Load(key string) (value string, ok bool)
-	Store(key, value string)
-	RangeExt(f func(key, value, user string) bool)
-	Range(f func(key, value string) bool)
-	LoadOrStore(key, value string) (actual string, loaded bool)
-	LoadOrStoreExt(key, value, user string) (actual string, loaded bool)
-	Delete(keys ...string)
-	Ping() error
-	Close() error
-}
+type StorageI = strg.StorageI
 
 // APIT - struct with api handlers
 type APIT struct {
+	appCtx  context.Context
+	cfg     *config.Holder
 	storage StorageI
 	logger  logger.Logger
+	norm    *urlnorm.Normalizer
+	blocked *blocklist.List
+	checker safety.SafetyChecker
+	// async - background work started by requests, pending - keys waiting for deletion, draining - set once shutdown began
+	async    *sync.WaitGroup
+	pending  *atomic.Int64
+	draining *atomic.Bool
+}
+
+// NewAPI() - constructor, handlers read cfg on each request so reloaded settings apply immediately
+func NewAPI(ctx context.Context, l logger.Logger, cfg *config.Holder, storage StorageI) APIT {
+	c := cfg.Get()
+	bl, err := blocklist.New(ctx, l, c.BlocklistPath, time.Duration(c.BlocklistReloadInterval))
+	if err != nil {
+		l.WithField("error", err).Error("Error while load blocklist")
+	}
+	context.AfterFunc(ctx, cfg.Subscribe(func(old, new config.Config) {
+		if old.BlocklistPath == new.BlocklistPath {
+			return
+		}
+		if err := bl.SetPath(new.BlocklistPath); err != nil {
+			l.WithField("error", err).Error("Error while switch blocklist")
+			return
+		}
+		l.WithField("path", new.BlocklistPath).Info("Blocklist switched")
+	}))
+	checker, err := safety.New(ctx, c.Safety)
+	if err != nil {
+		l.WithField("error", err).Error("Error while create safety checker")
+	}
+	if checker != nil && c.Safety.RescanInterval > 0 {
+		go safety.NewRescanner(checker, storage, l).
+			Run(ctx, time.Duration(c.Safety.RescanInterval))
+	}
+	return APIT{appCtx: ctx, cfg: cfg, storage: storage, logger: l, blocked: bl, checker: checker,
+		async: &sync.WaitGroup{}, pending: &atomic.Int64{}, draining: &atomic.Bool{}, norm: urlnorm.New(urlnorm.Options{
+			AllowedSchemes:     c.URL.AllowedSchemes,
+			MaxLength:          c.URL.MaxLength,
+			StripTrailingSlash: c.URL.StripTrailingSlash,
+			SortQuery:          c.URL.SortQuery,
+			BlockPrivate:       c.URL.BlockPrivate,
+			LookupTimeout:      time.Duration(c.URL.LookupTimeout),
+		})}
+}
+
+// log returns request scoped logger
+func (a APIT) log(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, a.logger)
+}
+
+// bodyStatus - status for error reading request body, 413 when body limit is exceeded
+func bodyStatus(err error) int {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// domain returns domain serving request Host
+func (a APIT) domain(r *http.Request) config.DomainConfig {
+	return a.cfg.Get().DomainByHost(r.Host)
 }
 
-// NewAPI() - constructor
-func NewAPI(ctx context.Context, l logger.Logger, storage StorageI) APIT {
-	return APIT{storage: storage, logger: l}
+// shortURL builds public url of key from base url of its domain
+func (a APIT) shortURL(key model.Key) (string, error) {
+	d, ok := a.cfg.Get().Domain(key.Domain)
+	if !ok {
+		return "", fmt.Errorf("unknown domain %q", key.Domain)
+	}
+	return url.JoinPath(d.BaseURL, key.Code)
 }
 
 //func getHash(b []byte) string {
@@ -79,34 +152,53 @@ func (a APIT) Default(w http.ResponseWriter, r *http.Request) {
 func (a APIT) PostPlainText(w http.ResponseWriter, r *http.Request) {
 	b, e := io.ReadAll(r.Body)
 	if e != nil {
-		a.logger.WithFields(map[string]interface{}{
-			"remoteAddr": r.RemoteAddr,
-			"uri":        r.RequestURI,
-			"error":      e,
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": e,
 		}).Warn("io.ReadAll error")
+		http.Error(w, e.Error(), bodyStatus(e))
+		return
+	}
+	originalURL, e := a.norm.Normalize(r.Context(), string(b))
+	if e != nil {
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": e,
+		}).Warn("url validation error")
 		http.Error(w, e.Error(), http.StatusBadRequest)
 		return
 	}
-	shortURL, e := url.JoinPath(
-		config.C.BaseURL,
-		getHash(b))
+	if a.blocked.BlockedURL(originalURL) {
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"url": originalURL,
+		}).Warn("blocked destination")
+		http.Error(w, "destination is blocked", http.StatusForbidden)
+		return
+	}
+	if v := a.checkSafety(r, originalURL); !v.Safe {
+		http.Error(w, "destination is unsafe: "+v.Threat, http.StatusForbidden)
+		return
+	}
+	d := a.domain(r)
+	key := model.Key{Domain: d.ID, Code: getHash([]byte(originalURL))}
+	shortURL, e := url.JoinPath(d.BaseURL, key.Code)
 	if e != nil {
-		a.logger.WithFields(map[string]interface{}{
-			"remoteAddr": r.RemoteAddr,
-			"uri":        r.RequestURI,
-			"error":      e,
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": e,
 		}).Warn("url.JoinPath error")
 		http.Error(w, e.Error(), http.StatusBadRequest)
 		return
 	}
 
-	_, exist := a.storage.LoadOrStoreExt(shortURL, string(b), middleware.GetIssuer(r.Context()).ID)
+	_, exist := a.storage.LoadOrStoreExt(r.Context(), key, originalURL, middleware.GetIssuer(r.Context()).ID)
 
 	if exist {
 		w.WriteHeader(http.StatusConflict)
 		_, _ = w.Write([]byte(shortURL))
 		return
 	}
+	a.auditCreate(r, key, originalURL)
 	w.WriteHeader(http.StatusCreated)
 	_, _ = w.Write([]byte(shortURL))
 }
@@ -118,28 +210,46 @@ func (a APIT) PostJSON(w http.ResponseWriter, r *http.Request) {
 
 	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
-		a.logger.WithFields(map[string]interface{}{
-			"remoteAddr": r.RemoteAddr,
-			"uri":        r.RequestURI,
-			"error":      err,
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": err,
 		}).Warn("json.Decode")
+		http.Error(w, err.Error(), bodyStatus(err))
+		return
+	}
+	originalURL, err := a.norm.Normalize(r.Context(), request.URL.String())
+	if err != nil {
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": err,
+		}).Warn("url validation")
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	shortURL, err := url.JoinPath(
-		config.C.BaseURL,
-		getHash([]byte(request.URL.String())))
+	if a.blocked.BlockedURL(originalURL) {
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"url": originalURL,
+		}).Warn("blocked destination")
+		http.Error(w, "destination is blocked", http.StatusForbidden)
+		return
+	}
+	if v := a.checkSafety(r, originalURL); !v.Safe {
+		http.Error(w, "destination is unsafe: "+v.Threat, http.StatusForbidden)
+		return
+	}
+	d := a.domain(r)
+	key := model.Key{Domain: d.ID, Code: getHash([]byte(originalURL))}
+	shortURL, err := url.JoinPath(d.BaseURL, key.Code)
 	if err != nil {
-		a.logger.WithFields(map[string]interface{}{
-			"remoteAddr": r.RemoteAddr,
-			"uri":        r.RequestURI,
-			"error":      err,
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": err,
 		}).Warn("url.JoinPath")
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	_, exist := a.storage.LoadOrStoreExt(shortURL, request.URL.String(), middleware.GetIssuer(r.Context()).ID)
+	_, exist := a.storage.LoadOrStoreExt(r.Context(), key, originalURL, middleware.GetIssuer(r.Context()).ID)
 
 	response.Result = shortURL
 	if exist {
@@ -147,6 +257,7 @@ func (a APIT) PostJSON(w http.ResponseWriter, r *http.Request) {
 		render.JSON(w, r, response)
 		return
 	}
+	a.auditCreate(r, key, originalURL)
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, response)
 }
@@ -154,9 +265,17 @@ func (a APIT) PostJSON(w http.ResponseWriter, r *http.Request) {
 // GetUserURLs - api handler
 func (a APIT) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	var lu model.ListURLs
-	a.storage.RangeExt(func(key, value, user string) bool {
+	a.storage.RangeExt(r.Context(), func(key model.Key, value, user string) bool {
+		shortURL, err := a.shortURL(key)
+		if err != nil {
+			a.log(r.Context()).WithFields(map[string]interface{}{
+				"key":   key.String(),
+				"error": err,
+			}).Warn("short url")
+			return true
+		}
 		lu = append(lu, model.ListURLRecordT{
-			ShortURL:    key,
+			ShortURL:    shortURL,
 			OriginalURL: value,
 			User:        user,
 		})
@@ -186,26 +305,33 @@ func (a APIT) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, lu)
 }
 
-// GetRoot - api handler
+// GetRoot - api handler, code is looked up in domain serving request Host.
+// Unknown codes redirect to default redirect of domain when it is set.
 func (a APIT) GetRoot(w http.ResponseWriter, r *http.Request) {
-	shortURL, e := url.JoinPath(
-		config.C.BaseURL,
-		chi.URLParam(r, "sn"))
-	if e != nil {
-		a.logger.WithFields(map[string]interface{}{
-			"remoteAddr": r.RemoteAddr,
-			"uri":        r.RequestURI,
-			"error":      e,
-		}).Warn("url.JoinPath")
-		http.Error(w, e.Error(), http.StatusBadRequest)
-		return
-	}
+	d := a.domain(r)
+	key := model.Key{Domain: d.ID, Code: chi.URLParam(r, "sn")}
 
-	s, ok := a.storage.Load(shortURL)
+	s, ok := a.storage.Load(r.Context(), key)
 	if !ok {
+		if d.DefaultRedirect != "" {
+			http.Redirect(w, r, d.DefaultRedirect, http.StatusFound)
+			return
+		}
 		w.WriteHeader(http.StatusGone)
 		return
 	}
+	switch st := a.storage.Status(r.Context(), key); st.State {
+	case model.StateDisabled:
+		http.Error(w, "link disabled: "+st.Reason, http.StatusGone)
+		return
+	case model.StateQuarantined:
+		a.interstitial(w, r, s, st.Reason)
+		return
+	}
+	if a.blocked.BlockedURL(s) {
+		http.Error(w, "destination is blocked", http.StatusForbidden)
+		return
+	}
 	w.Header().Set("Location", s)
 	w.WriteHeader(http.StatusTemporaryRedirect)
 	w.Write([]byte(s))
@@ -213,7 +339,11 @@ func (a APIT) GetRoot(w http.ResponseWriter, r *http.Request) {
 
 // GetPing - api handler
 func (a APIT) GetPing(w http.ResponseWriter, r *http.Request) {
-	err := a.storage.Ping()
+	if a.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	err := a.storage.Ping(r.Context())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -221,34 +351,83 @@ func (a APIT) GetPing(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// Drain - fails ping so load balancers stop routing, called when shutdown begins
+func (a APIT) Drain() {
+	a.draining.Store(true)
+}
+
+// DeleteBacklog - number of keys waiting for asynchronous deletion
+func (a APIT) DeleteBacklog() int64 {
+	return a.pending.Load()
+}
+
+// Wait - waits for background work started by requests, e.g. deletions
+func (a APIT) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		a.async.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // PostBatch - api handler
 func (a APIT) PostBatch(w http.ResponseWriter, r *http.Request) {
 	var batch model.Batch
 
 	err := json.NewDecoder(r.Body).Decode(&batch)
 	if err != nil {
-		a.logger.WithFields(map[string]interface{}{
-			"remoteAddr": r.RemoteAddr,
-			"uri":        r.RequestURI,
-			"error":      err,
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": err,
 		}).Warn("json.Decode")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), bodyStatus(err))
 		return
 	}
 	for i := range batch {
-		batch[i].ShortURL, err = url.JoinPath(
-			config.C.BaseURL,
-			getHash([]byte(batch[i].OriginalURL)))
+		batch[i].OriginalURL, err = a.norm.Normalize(r.Context(), batch[i].OriginalURL)
 		if err != nil {
-			a.logger.WithFields(map[string]interface{}{
-				"remoteAddr": r.RemoteAddr,
-				"uri":        r.RequestURI,
-				"error":      err,
+			a.log(r.Context()).WithFields(map[string]interface{}{
+				"uri":           r.RequestURI,
+				"correlationID": batch[i].CorrelationID,
+				"error":         err,
+			}).Warn("url validation")
+			http.Error(w, batch[i].CorrelationID+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if a.blocked.BlockedURL(batch[i].OriginalURL) {
+			a.log(r.Context()).WithFields(map[string]interface{}{
+				"url":           batch[i].OriginalURL,
+				"correlationID": batch[i].CorrelationID,
+			}).Warn("blocked destination")
+			http.Error(w, batch[i].CorrelationID+": destination is blocked", http.StatusForbidden)
+			return
+		}
+		if v := a.checkSafety(r, batch[i].OriginalURL); !v.Safe {
+			http.Error(w, batch[i].CorrelationID+": destination is unsafe: "+v.Threat, http.StatusForbidden)
+			return
+		}
+	}
+	d := a.domain(r)
+	for i := range batch {
+		key := model.Key{Domain: d.ID, Code: getHash([]byte(batch[i].OriginalURL))}
+		batch[i].ShortURL, err = url.JoinPath(d.BaseURL, key.Code)
+		if err != nil {
+			a.log(r.Context()).WithFields(map[string]interface{}{
+				"uri":   r.RequestURI,
+				"error": err,
 			}).Warn("url.JoinPath")
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		a.storage.Store(batch[i].ShortURL, batch[i].OriginalURL)
+		if _, loaded := a.storage.LoadOrStoreExt(r.Context(), key, batch[i].OriginalURL, middleware.GetIssuer(r.Context()).ID); !loaded {
+			a.auditCreate(r, key, batch[i].OriginalURL)
+		}
 		batch[i].OriginalURL = ""
 	}
 
@@ -256,33 +435,46 @@ func (a APIT) PostBatch(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, batch)
 }
 
-// DeleteUserUrls - api handler
+// DeleteUserUrls - api handler, codes are deleted in domain serving request Host
 func (a APIT) DeleteUserUrls(w http.ResponseWriter, r *http.Request) {
 	var batch model.BatchDelete
 
 	err := json.NewDecoder(r.Body).Decode(&batch)
 	if err != nil {
-		a.logger.WithFields(map[string]interface{}{
-			"remoteAddr": r.RemoteAddr,
-			"uri":        r.RequestURI,
-			"error":      err,
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": err,
 		}).Warn("json.Decode")
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), bodyStatus(err))
 		return
 	}
 
+	d := a.domain(r)
+	keys := make([]model.Key, len(batch))
+	events := make([]model.AuditEvent, len(batch))
 	for i := range batch {
-		batch[i], err = url.JoinPath(config.C.BaseURL, batch[i])
-		if err != nil {
-			a.logger.WithFields(map[string]interface{}{
-				"remoteAddr": r.RemoteAddr,
-				"uri":        r.RequestURI,
-				"error":      err,
-			}).Warn("url.JoinPath")
-		}
+		keys[i] = model.Key{Domain: d.ID, Code: batch[i]}
+		events[i] = auditEvent(r, model.ActionDelete, keys[i])
 	}
 
-	go a.storage.Delete(batch...)
+	metrics.DeleteQueue.Add(float64(len(batch)))
+	a.pending.Add(int64(len(batch)))
+	ctx := logger.NewContext(a.appCtx, a.logger, logger.Fields{"requestID": middleware.GetRequestID(r.Context())})
+	a.async.Add(1)
+	go func() {
+		defer a.async.Done()
+		defer a.pending.Add(-int64(len(batch)))
+		defer metrics.DeleteQueue.Sub(float64(len(batch)))
+		for i := range keys {
+			events[i].OldURL, _ = a.storage.Load(ctx, keys[i])
+		}
+		a.storage.Delete(ctx, keys...)
+		for i := range events {
+			if events[i].OldURL != "" {
+				a.addAudit(ctx, events[i])
+			}
+		}
+	}()
 
 	w.WriteHeader(http.StatusAccepted)
 }
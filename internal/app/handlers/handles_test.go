@@ -8,16 +8,21 @@ import (
 	"github.com/Stas9132/shortener/config"
 	"github.com/Stas9132/shortener/internal/app/handlers/middleware"
 	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/app/safety"
 	strg "github.com/Stas9132/shortener/internal/app/storage"
 	"github.com/Stas9132/shortener/internal/logger"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"testing/iotest"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -30,8 +35,27 @@ var _ = func() bool {
 	return true
 }()
 
-var storage, _ = strg.NewFileStorage(context.Background(), logger.NewDummy())
-var api = NewAPI(context.Background(), logger.NewDummy(), storage)
+var storage, _ = strg.NewFileStorage(context.Background(), logger.NewDummy(), config.Default())
+var api = NewAPI(context.Background(), logger.NewDummy(), config.NewHolder(config.Default()), storage)
+
+// newAPI - api over in-memory storage with config adjusted by f
+func newAPI(f func(c *config.Config)) (APIT, *strg.FileStorageT) {
+	c := config.Default()
+	if f != nil {
+		f(&c)
+	}
+	s, _ := strg.NewFileStorage(context.Background(), logger.NewDummy(), c)
+	return NewAPI(context.Background(), logger.NewDummy(), config.NewHolder(c), s), s
+}
+
+// keyOf - key of short url created on default domain
+func keyOf(shortURL string) model.Key {
+	return model.Key{Domain: config.DefaultDomainID, Code: path.Base(shortURL)}
+}
+
+func badBaseURL(c *config.Config) {
+	c.BaseURL = "ht\tp://wewe.we/"
+}
 
 func Test_getHash(t *testing.T) {
 	type args struct {
@@ -151,8 +175,7 @@ got status BadRequest`,
 }
 
 func TestPostPlainText(t *testing.T) {
-	s, _ := strg.NewFileStorage(context.Background(), logger.NewDummy())
-	a := NewAPI(context.Background(), logger.NewDummy(), s)
+	a, s := newAPI(nil)
 	type args struct {
 		body io.Reader
 	}
@@ -166,6 +189,18 @@ send correct URL
 got status Created`,
 		args:       args{body: strings.NewReader("https://go.dev/")},
 		wantStatus: http.StatusCreated,
+	}, {
+		name: `Invalid URL:
+send javascript url
+got status BadRequest`,
+		args:       args{body: strings.NewReader("javascript:alert(1)")},
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name: `Empty body:
+send whitespace
+got status BadRequest`,
+		args:       args{body: strings.NewReader(" \n")},
+		wantStatus: http.StatusBadRequest,
 	}, {
 		name: `#1 Error on io.ReadALL
 send bad request
@@ -180,21 +215,28 @@ set bad config variables
 got status BadRequest`,
 		args:       args{body: strings.NewReader("https://go.dev/")},
 		wantStatus: http.StatusBadRequest,
+	}, {
+		name: `#3 Body over limit
+send URL longer than request size limit
+got status RequestEntityTooLarge`,
+		args:       args{body: strings.NewReader("https://go.dev/")},
+		wantStatus: http.StatusRequestEntityTooLarge,
 	}}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			switch {
 			case strings.HasPrefix(tt.name, "#1"):
 				tt.args.body = iotest.ErrReader(errors.New("io error occurred"))
-			case strings.HasPrefix(tt.name, "#2"):
-				wk := config.C.BaseURL
-				defer func() {
-					config.C.BaseURL = wk
-				}()
-				config.C.BaseURL = "ht\tp://wewe.we/"
+			}
+			a := a
+			if strings.HasPrefix(tt.name, "#2") {
+				a, _ = newAPI(badBaseURL)
 			}
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest(http.MethodPost, "http://localhost/", tt.args.body)
+			if strings.HasPrefix(tt.name, "#3") {
+				r.Body = http.MaxBytesReader(w, r.Body, 8)
+			}
 			middleware.Authorization(http.HandlerFunc(a.PostPlainText)).ServeHTTP(w, r)
 			resp := w.Result()
 			defer resp.Body.Close()
@@ -202,7 +244,7 @@ got status BadRequest`,
 			if resp.StatusCode == http.StatusCreated {
 				b, err := io.ReadAll(resp.Body)
 				require.NoError(t, err)
-				_, ok := s.Load(string(b))
+				_, ok := s.Load(context.Background(), keyOf(string(b)))
 				assert.True(t, ok)
 			}
 		})
@@ -253,16 +295,14 @@ got status BadRequest`,
 			switch {
 			case strings.HasPrefix(tt.name, "#1"):
 				tt.body = iotest.ErrReader(errors.New("io error occurred"))
-			case strings.HasPrefix(tt.name, "#2"):
-				wk := config.C.BaseURL
-				defer func() {
-					config.C.BaseURL = wk
-				}()
-				config.C.BaseURL = "ht\tp://wewe.we/"
+			}
+			a := api
+			if strings.HasPrefix(tt.name, "#2") {
+				a, _ = newAPI(badBaseURL)
 			}
 			w := httptest.NewRecorder()
 			r := httptest.NewRequest(http.MethodPost, "http://localhost/", tt.body)
-			api.PostJSON(w, r)
+			a.PostJSON(w, r)
 			resp := w.Result()
 			defer resp.Body.Close()
 			assert.Equal(t, tt.wantStatus, resp.StatusCode)
@@ -270,7 +310,7 @@ got status BadRequest`,
 				var mr model.Response
 				err := json.NewDecoder(resp.Body).Decode(&mr)
 				require.NoError(t, err)
-				_, ok := storage.Load(mr.Result)
+				_, ok := storage.Load(context.Background(), keyOf(mr.Result))
 				assert.True(t, ok)
 			}
 		})
@@ -278,8 +318,7 @@ got status BadRequest`,
 }
 
 func TestGetUserURLs(t *testing.T) {
-	s, _ := strg.NewFileStorage(context.Background(), logger.NewDummy())
-	a := NewAPI(context.Background(), logger.NewDummy(), s)
+	a, s := newAPI(nil)
 	srv := httptest.NewServer(http.HandlerFunc(a.GetUserURLs))
 	defer srv.Close()
 	tests := []struct {
@@ -312,7 +351,7 @@ list with two record`,
 			require.NoError(t, err)
 			req.Header.Set("Accept-Encoding", "identity")
 			resp, err := (&http.Client{}).Do(req)
-			s.Store(uuid.NewString(), "ok")
+			s.Store(context.Background(), keyOf(uuid.NewString()), "ok")
 			require.NoError(t, err)
 			defer resp.Body.Close()
 			require.Equal(t, tt.wantStatus, resp.StatusCode)
@@ -345,3 +384,280 @@ func FuzzGetHash(f *testing.F) {
 		m[h] = []byte(s)
 	})
 }
+
+func TestReportAndDisable(t *testing.T) {
+	a, _ := newAPI(func(c *config.Config) { c.AdminToken = "admin" })
+
+	r := chi.NewRouter()
+	r.Post("/", a.PostPlainText)
+	r.Get("/{sn}", a.GetRoot)
+	r.Post("/api/report/{code}", a.PostReport)
+	r.With(middleware.AdminOnly(a.cfg)).Get("/api/admin/reports", a.GetReports)
+	r.With(middleware.AdminOnly(a.cfg)).Post("/api/admin/disable/{code}", a.PostDisable)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	cl := http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	do := func(method, path, body, token string) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("X-Admin-Token", token)
+		}
+		resp, err := cl.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := do(http.MethodPost, "/", "https://go.dev/", "")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	code := "/a7930003"
+
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/report/ffffffff", `{"reason":"spam"}`, "").StatusCode)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/report"+code, `{"reason":""}`, "").StatusCode)
+	assert.Equal(t, http.StatusAccepted, do(http.MethodPost, "/api/report"+code, `{"reason":"phishing"}`, "").StatusCode)
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "/api/admin/reports", "", "wrong").StatusCode)
+	resp = do(http.MethodGet, "/api/admin/reports", "", "admin")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var reports []model.Report
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&reports))
+	require.Len(t, reports, 1)
+	assert.Equal(t, "phishing", reports[0].Reason)
+
+	assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, code, "", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPost, "/api/admin/disable/ffffffff", `{"reason":"x"}`, "admin").StatusCode)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodPost, "/api/admin/disable"+code, `{"reason":"phishing"}`, "admin").StatusCode)
+	resp = do(http.MethodGet, code, "", "")
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "phishing")
+}
+
+type stubChecker map[string]string
+
+func (c stubChecker) Check(_ context.Context, rawURL string) (safety.Verdict, error) {
+	if threat, ok := c[rawURL]; ok {
+		return safety.Verdict{Threat: threat}, nil
+	}
+	return safety.Verdict{Safe: true}, nil
+}
+
+func TestSafetyCheck(t *testing.T) {
+	a, s := newAPI(nil)
+	a.checker = stubChecker{"http://malware.test/": "MALWARE"}
+
+	w := httptest.NewRecorder()
+	a.PostPlainText(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("http://malware.test/")))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "MALWARE")
+
+	w = httptest.NewRecorder()
+	a.PostPlainText(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://go.dev/")))
+	require.Equal(t, http.StatusCreated, w.Code)
+	shortURL := w.Body.String()
+	require.NoError(t, s.SetStatus(context.Background(), keyOf(shortURL), model.LinkStatus{State: model.StateQuarantined, Reason: "MALWARE"}))
+
+	r := chi.NewRouter()
+	r.Get("/{sn}", a.GetRoot)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, shortURL[strings.LastIndex(shortURL, "/"):], nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), "may be unsafe")
+	assert.Contains(t, w.Body.String(), "https://go.dev/")
+}
+
+func TestAudit(t *testing.T) {
+	c := config.Default()
+	c.FileStoragePath = t.TempDir() + "/storage.json"
+	c.AdminToken = "admin"
+	s, err := strg.NewFileStorage(context.Background(), logger.NewDummy(), c)
+	require.NoError(t, err)
+	defer s.Close()
+	a := NewAPI(context.Background(), logger.NewDummy(), config.NewHolder(c), s)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID(logger.NewDummy()))
+	r.Post("/", a.PostPlainText)
+	r.Post("/api/shorten/batch", a.PostBatch)
+	r.Delete("/api/user/urls", a.DeleteUserUrls)
+	r.With(middleware.AdminOnly(a.cfg)).Post("/api/admin/disable/{code}", a.PostDisable)
+	r.With(middleware.AdminOnly(a.cfg)).Get("/api/admin/audit", a.GetAudit)
+	r.With(middleware.AdminOnly(a.cfg)).Get("/api/admin/audit/verify", a.GetAuditVerify)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Admin-Token", "admin")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	events := func(query string) []model.AuditEvent {
+		w := do(http.MethodGet, "/api/admin/audit"+query, "")
+		if w.Code == http.StatusNoContent {
+			return nil
+		}
+		require.Equal(t, http.StatusOK, w.Code)
+		var res []model.AuditEvent
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		return res
+	}
+
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/", "https://go.dev/").Code)
+	require.Equal(t, http.StatusConflict, do(http.MethodPost, "/", "https://go.dev/").Code)
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/api/shorten/batch",
+		`[{"correlation_id":"1","original_url":"https://go.dev/"},{"correlation_id":"2","original_url":"https://go.dev/doc/"}]`).Code)
+	require.Equal(t, http.StatusNoContent, do(http.MethodPost, "/api/admin/disable/a7930003", `{"reason":"spam"}`).Code)
+	require.Equal(t, http.StatusAccepted, do(http.MethodDelete, "/api/user/urls", `["a7930003","ffffffff"]`).Code)
+	require.Eventually(t, func() bool { return len(events("")) == 4 }, time.Second, 10*time.Millisecond)
+
+	all := events("")
+	assert.Equal(t, []string{model.ActionCreate, model.ActionCreate, model.ActionDisable, model.ActionDelete},
+		[]string{all[0].Action, all[1].Action, all[2].Action, all[3].Action})
+	assert.Equal(t, "https://go.dev/", all[0].NewURL)
+	assert.Equal(t, "spam", all[2].Reason)
+	assert.Equal(t, "https://go.dev/", all[3].OldURL)
+	assert.NotEmpty(t, all[0].RequestID)
+	s.RangeExt(context.Background(), func(key model.Key, value, user string) bool {
+		if value == "https://go.dev/doc/" {
+			assert.Equal(t, all[1].Actor, user, "batch link owned by audited actor")
+		}
+		return true
+	})
+
+	assert.Len(t, events("?code=a7930003"), 3)
+	assert.Len(t, events("?user=nobody"), 0)
+	assert.Len(t, events("?from="+all[3].Time.Add(time.Second).Format(time.RFC3339)), 0)
+	assert.Len(t, events("?to="+all[0].Time.Add(time.Second).Format(time.RFC3339)), 4)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/admin/audit?from=yesterday", "").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/admin/audit/verify", "").Code)
+
+	reopened, err := strg.NewFileStorage(context.Background(), logger.NewDummy(), c)
+	require.NoError(t, err)
+	defer reopened.Close()
+	got, err := reopened.AuditEvents(context.Background(), model.AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, all, got)
+
+	b, err := os.ReadFile(c.FileStoragePath + ".audit")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(c.FileStoragePath+".audit",
+		bytes.Replace(b, []byte("https://go.dev/doc/"), []byte("https://go.dev/dog/"), 1), 0600))
+	tampered, err := strg.NewFileStorage(context.Background(), logger.NewDummy(), c)
+	require.NoError(t, err)
+	defer tampered.Close()
+	w := httptest.NewRecorder()
+	NewAPI(context.Background(), logger.NewDummy(), config.NewHolder(c), tampered).
+		GetAuditVerify(w, httptest.NewRequest(http.MethodGet, "/api/admin/audit/verify", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestDomains(t *testing.T) {
+	c := config.Default()
+	c.FileStoragePath = t.TempDir() + "/storage.json"
+	c.Domains = []config.DomainConfig{
+		{ID: config.DefaultDomainID, BaseURL: "http://localhost:8080/"},
+		{ID: "a", BaseURL: "https://a.test/s/"},
+		{ID: "b", Host: "b.test", BaseURL: "https://b.test/", DefaultRedirect: "https://b.test/home"},
+	}
+	require.NoError(t, c.Validate())
+	require.NoError(t, os.WriteFile(c.FileStoragePath,
+		[]byte(`[{"uuid":"u","short_url":"https://a.test/s/0000abcd","original_url":"https://go.dev/"},`+
+			`{"uuid":"u","short_url":"http://old.test/1111abcd","original_url":"https://go.dev/doc/"}]`), 0644))
+	require.NoError(t, os.WriteFile(c.FileStoragePath+".reports",
+		[]byte(`{"short_url":"https://a.test/s/0000abcd","reason":"spam","reporter":"u","remote_addr":"","created_at":"2024-01-01T00:00:00Z"}`+"\n"), 0644))
+	s, err := strg.NewFileStorage(context.Background(), logger.NewDummy(), c)
+	require.NoError(t, err)
+	defer s.Close()
+	a := NewAPI(context.Background(), logger.NewDummy(), config.NewHolder(c), s)
+
+	r := chi.NewRouter()
+	r.Post("/", a.PostPlainText)
+	r.Get("/{sn}", a.GetRoot)
+	do := func(method, host, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Host = host
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Legacy keys migrated", func(t *testing.T) {
+		v, ok := s.Load(context.Background(), model.Key{Domain: "a", Code: "0000abcd"})
+		assert.True(t, ok)
+		assert.Equal(t, "https://go.dev/", v)
+		_, ok = s.Load(context.Background(), model.Key{Domain: config.DefaultDomainID, Code: "1111abcd"})
+		assert.True(t, ok)
+		reports, err := s.Reports(context.Background())
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, model.Key{Domain: "a", Code: "0000abcd"}, reports[0].Key)
+		b, err := os.ReadFile(c.FileStoragePath)
+		require.NoError(t, err)
+		assert.NotContains(t, string(b), "short_url")
+	})
+
+	t.Run("Created on request host", func(t *testing.T) {
+		w := do(http.MethodPost, "a.test", "/", "https://go.dev/blog/")
+		require.Equal(t, http.StatusCreated, w.Code)
+		assert.True(t, strings.HasPrefix(w.Body.String(), "https://a.test/s/"))
+		code := path.Base(w.Body.String())
+		assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "a.test:443", "/"+code, "").Code)
+		assert.Equal(t, http.StatusGone, do(http.MethodGet, "localhost:8080", "/"+code, "").Code)
+	})
+
+	t.Run("Same url on two domains", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, do(http.MethodPost, "b.test", "/", "https://go.dev/blog/").Code)
+		assert.Equal(t, http.StatusConflict, do(http.MethodPost, "a.test", "/", "https://go.dev/blog/").Code)
+	})
+
+	t.Run("Default redirect", func(t *testing.T) {
+		w := do(http.MethodGet, "b.test", "/ffffffff", "")
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://b.test/home", w.Header().Get("Location"))
+	})
+
+	t.Run("Unknown host served by default domain", func(t *testing.T) {
+		assert.Equal(t, http.StatusTemporaryRedirect, do(http.MethodGet, "other.test", "/1111abcd", "").Code)
+	})
+}
+
+// slowDelete - storage holding Delete until release is closed
+type slowDelete struct {
+	StorageI
+	release chan struct{}
+	deleted atomic.Bool
+}
+
+func (s *slowDelete) Delete(ctx context.Context, keys ...model.Key) {
+	<-s.release
+	s.StorageI.Delete(ctx, keys...)
+	s.deleted.Store(true)
+}
+
+func TestShutdown(t *testing.T) {
+	st, _ := strg.NewFileStorage(context.Background(), logger.NewDummy(), config.Default())
+	s := &slowDelete{StorageI: st, release: make(chan struct{})}
+	a := NewAPI(context.Background(), logger.NewDummy(), config.NewHolder(config.Default()), s)
+
+	w := httptest.NewRecorder()
+	a.DeleteUserUrls(w, httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["a7930003"]`)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, a.Wait(ctx), context.DeadlineExceeded, "deletion in progress")
+	close(s.release)
+	assert.NoError(t, a.Wait(context.Background()))
+	assert.True(t, s.deleted.Load())
+
+	w = httptest.NewRecorder()
+	a.GetPing(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	a.Drain()
+	w = httptest.NewRecorder()
+	a.GetPing(w, httptest.NewRequest(http.MethodGet, "/ping", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "readiness fails while draining")
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/logger"
+	"net/http"
+)
+
+// AdminOnly middleware - passes requests with X-Admin-Token header equal to current cfg admin token
+func AdminOnly(cfg *config.Holder) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, want := r.Header.Get("X-Admin-Token"), cfg.Get().AdminToken
+			if want == "" || subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+				logger.WithField("remoteAddr", r.RemoteAddr).Warn("Admin access denied")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -17,6 +17,8 @@ const key = "secret_key"
 type Issuer struct {
 	ID    string
 	State string
+	// Subject - client certificate subject when issuer is authenticated by certificate
+	Subject string
 }
 
 // GetIssuer from context
@@ -43,9 +45,14 @@ func (w authWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
-// Authorization middleware
+// Authorization middleware, requests with Issuer already set by ClientCert pass without cookie
 func Authorization(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if iss := certIssuer(r.Context()); iss != nil {
+			logger.AddFields(r.Context(), logger.Fields{"issuer": iss.ID})
+			h.ServeHTTP(w, r)
+			return
+		}
 		c, err := r.Cookie("auth")
 		iss := Issuer{
 			ID:    uuid.NewString(),
@@ -70,19 +77,20 @@ func Authorization(h http.Handler) http.Handler {
 			err = err2
 		}
 		if err != nil {
-			logger.WithField("error", err).Info("Token error")
+			logger.FromContext(r.Context(), logger.Default()).WithField("error", err).Info("Token error")
 			j, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 				"iss": iss.ID,
 				"exp": time.Now().Add(72 * time.Hour).Unix(),
 			}).SignedString([]byte(key))
 			if err != nil {
-				logger.WithField("error", err).Errorln("error while create jwt token")
+				logger.WithField("error", err).Error("error while create jwt token")
 			}
 			c = &http.Cookie{
 				Name:  "auth",
 				Value: j,
 			}
 		}
+		logger.AddFields(r.Context(), logger.Fields{"issuer": iss.ID})
 		h.ServeHTTP(authWriter{
 			c:              c,
 			ResponseWriter: w,
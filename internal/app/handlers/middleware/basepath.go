@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/Stas9132/shortener/config"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BasePath middleware - serves short links of domains whose base url has a path:
+// GET of the path followed by a code is routed as GET of the code at root
+func BasePath(cfg *config.Holder) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				h.ServeHTTP(w, r)
+				return
+			}
+			d := cfg.Get().DomainByHost(r.Host)
+			u, err := url.Parse(d.BaseURL)
+			if err != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			prefix := strings.TrimSuffix(u.Path, "/") + "/"
+			code, ok := strings.CutPrefix(r.URL.Path, prefix)
+			if prefix == "/" || !ok || code == "" || strings.Contains(code, "/") {
+				h.ServeHTTP(w, r)
+				return
+			}
+			r2 := r.Clone(r.Context())
+			r2.URL.Path, r2.URL.RawPath = "/"+code, ""
+			h.ServeHTTP(w, r2)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasePath(t *testing.T) {
+	c := config.Default()
+	c.Domains = []config.DomainConfig{
+		{ID: "a", BaseURL: "https://a.test/s/"},
+		{ID: "b", BaseURL: "https://b.test/"},
+	}
+	h := BasePath(config.NewHolder(c))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	tests := []struct {
+		name, method, host, path, want string
+	}{
+		{name: "Code under base path", method: http.MethodGet, host: "a.test", path: "/s/abc", want: "/abc"},
+		{name: "Code at root", method: http.MethodGet, host: "a.test", path: "/abc", want: "/abc"},
+		{name: "Nested path kept", method: http.MethodGet, host: "a.test", path: "/s/x/abc", want: "/s/x/abc"},
+		{name: "Base path alone kept", method: http.MethodGet, host: "a.test", path: "/s/", want: "/s/"},
+		{name: "Post kept", method: http.MethodPost, host: "a.test", path: "/s/abc", want: "/s/abc"},
+		{name: "Domain without path", method: http.MethodGet, host: "b.test", path: "/s/abc", want: "/s/abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Host = tt.host
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/logger"
+	"net/http"
+	"strings"
+)
+
+// CertIdentity - issuer id of client certificate: mapped one when any of its names is in identities,
+// otherwise first URI, DNS or email SAN, then common name
+func CertIdentity(cert *x509.Certificate, identities map[string]string) string {
+	var names []string
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	if cert.Subject.CommonName != "" {
+		names = append(names, cert.Subject.CommonName)
+	}
+	for _, n := range append(names, cert.Subject.String()) {
+		if id, ok := identities[n]; ok {
+			return id
+		}
+	}
+	if len(names) == 0 {
+		return cert.Subject.String()
+	}
+	return names[0]
+}
+
+// ClientCert middleware - sets Issuer from verified client certificate, Authorization keeps it and issues no cookie
+func ClientCert(cfg *config.Holder) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+				h.ServeHTTP(w, r)
+				return
+			}
+			cert := r.TLS.VerifiedChains[0][0]
+			iss := Issuer{
+				ID:      CertIdentity(cert, cfg.Get().TLS.ClientIdentities),
+				State:   "ESTABLISHED",
+				Subject: cert.Subject.String(),
+			}
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), Issuer{}, &iss)))
+		})
+	}
+}
+
+// certIssuer returns issuer set by ClientCert, nil for requests without certificate
+func certIssuer(ctx context.Context) *Issuer {
+	if iss, ok := ctx.Value(Issuer{}).(*Issuer); ok && iss.Subject != "" {
+		return iss
+	}
+	return nil
+}
+
+// certRequired reports whether path matches one of routes, "/prefix/*" matches prefix and everything below
+func certRequired(routes []string, path string) bool {
+	for _, route := range routes {
+		if prefix, ok := strings.CutSuffix(route, "/*"); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		} else if path == route {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientCertPolicy middleware, goes after ClientCert - rejects requests to configured client cert routes made without verified certificate
+func ClientCertPolicy(cfg *config.Holder) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if certRequired(cfg.Get().TLS.ClientCertRoutes, r.URL.Path) && certIssuer(r.Context()) == nil {
+				logger.FromContext(r.Context(), logger.Default()).Warn("Client certificate required")
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
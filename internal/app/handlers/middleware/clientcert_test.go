@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertIdentity(t *testing.T) {
+	uri, _ := url.Parse("spiffe://cluster/analytics")
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		identities map[string]string
+		want       string
+	}{
+		{name: "URI SAN first", cert: &x509.Certificate{URIs: []*url.URL{uri}, DNSNames: []string{"a.svc"},
+			Subject: pkix.Name{CommonName: "a"}}, want: "spiffe://cluster/analytics"},
+		{name: "DNS SAN", cert: &x509.Certificate{DNSNames: []string{"a.svc"}, Subject: pkix.Name{CommonName: "a"}}, want: "a.svc"},
+		{name: "Email SAN", cert: &x509.Certificate{EmailAddresses: []string{"ops@example.com"}}, want: "ops@example.com"},
+		{name: "Common name", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "a"}}, want: "a"},
+		{name: "Subject", cert: &x509.Certificate{Subject: pkix.Name{Organization: []string{"Org"}}}, want: "O=Org"},
+		{name: "Mapped SAN", cert: &x509.Certificate{URIs: []*url.URL{uri}, DNSNames: []string{"a.svc"}},
+			identities: map[string]string{"a.svc": "svc-a"}, want: "svc-a"},
+		{name: "Mapped subject", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "a", Organization: []string{"Org"}}},
+			identities: map[string]string{"CN=a,O=Org": "svc-a"}, want: "svc-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CertIdentity(tt.cert, tt.identities))
+		})
+	}
+}
+
+func TestCertRequired(t *testing.T) {
+	routes := []string{"/api/internal/*", "/ping"}
+	for path, want := range map[string]bool{
+		"/api/internal":         true,
+		"/api/internal/shorten": true,
+		"/api/internalx":        false,
+		"/ping":                 true,
+		"/ping/x":               false,
+		"/abc":                  false,
+	} {
+		assert.Equal(t, want, certRequired(routes, path), path)
+	}
+}
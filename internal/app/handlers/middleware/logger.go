@@ -4,8 +4,6 @@ import (
 	"github.com/Stas9132/shortener/internal/logger"
 	"net/http"
 	"time"
-
-	"github.com/sirupsen/logrus"
 )
 
 type responseData struct {
@@ -30,7 +28,7 @@ func (r loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode
 }
 
-// RequestLogger - middleware
+// RequestLogger - middleware writing single access log line per request
 func RequestLogger(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t := time.Now()
@@ -39,14 +37,15 @@ func RequestLogger(h http.Handler) http.Handler {
 			responseData:   &responseData{},
 		}
 		h.ServeHTTP(lw, r)
-		logger.WithFields(logrus.Fields{
+		if lw.responseData.status == 0 {
+			lw.responseData.status = http.StatusOK
+		}
+		logger.FromContext(r.Context(), logger.Default()).WithFields(logger.Fields{
 			"uri":      r.URL.RequestURI(),
 			"method":   r.Method,
 			"duration": time.Since(t),
-		}).Infoln("Request")
-		logger.WithFields(logrus.Fields{
-			"status": lw.responseData.status,
-			"size":   lw.responseData.size,
-		}).Infoln("Response")
+			"status":   lw.responseData.status,
+			"size":     lw.responseData.size,
+		}).Info("Request")
 	})
 }
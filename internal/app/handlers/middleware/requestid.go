@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Stas9132/shortener/internal/logger"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader - header carrying request id
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// GetRequestID from context
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID accepts ids of printable ascii up to 128 bytes
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// routePattern resolves matched chi route when log entry is written,
+// the pattern is not known yet when middleware runs
+type routePattern struct {
+	rctx *chi.Context
+}
+
+// String - method
+func (p routePattern) String() string {
+	if p.rctx == nil {
+		return ""
+	}
+	return p.rctx.RoutePattern()
+}
+
+// MarshalJSON - method
+func (p routePattern) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// RequestID middleware - assigns request id, honouring incoming X-Request-ID,
+// and stores request scoped logger derived from l in context
+func RequestID(l logger.Logger) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			ctx = logger.NewContext(ctx, l, logger.Fields{
+				"requestID":  id,
+				"remoteAddr": r.RemoteAddr,
+				"route":      routePattern{chi.RouteContext(r.Context())},
+			})
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
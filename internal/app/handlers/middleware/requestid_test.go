@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"github.com/Stas9132/shortener/internal/logger"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "Generated", incoming: "", keep: false},
+		{name: "Honoured", incoming: "abc-123", keep: true},
+		{name: "Too long replaced", incoming: strings.Repeat("a", 129), keep: false},
+		{name: "Control chars replaced", incoming: "a\tb", keep: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields logger.Fields
+			rec := logger.NewRecorder()
+			r := chi.NewRouter()
+			r.Use(RequestID(rec), Authorization)
+			r.Get("/{sn}", func(w http.ResponseWriter, r *http.Request) {
+				logger.FromContext(r.Context(), logger.NewDummy()).Info("handler")
+				entries := rec.Entries()
+				require.NotEmpty(t, entries)
+				fields = entries[len(entries)-1].Fields
+				assert.Equal(t, GetRequestID(r.Context()), fields["requestID"])
+			})
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			require.NotEmpty(t, id)
+			if tt.keep {
+				assert.Equal(t, tt.incoming, id)
+			} else {
+				assert.NotEqual(t, tt.incoming, id)
+			}
+			assert.Equal(t, id, fields["requestID"])
+			assert.Equal(t, req.RemoteAddr, fields["remoteAddr"])
+			assert.NotEmpty(t, fields["issuer"])
+			assert.Equal(t, "/{sn}", fields["route"].(interface{ String() string }).String())
+		})
+	}
+}
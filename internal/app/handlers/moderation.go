@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Stas9132/shortener/internal/app/handlers/middleware"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/app/safety"
+	strg "github.com/Stas9132/shortener/internal/app/storage"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+// PostReport - api handler, stores abuse report for admin review
+func (a APIT) PostReport(w http.ResponseWriter, r *http.Request) {
+	var request model.ReportRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": err,
+		}).Warn("json.Decode")
+		http.Error(w, err.Error(), bodyStatus(err))
+		return
+	}
+	if strings.TrimSpace(request.Reason) == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	key := model.Key{Domain: a.domain(r).ID, Code: chi.URLParam(r, "code")}
+	if _, ok := a.storage.Load(r.Context(), key); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err = a.storage.AddReport(r.Context(), model.Report{
+		Key:        key,
+		Reason:     request.Reason,
+		Reporter:   middleware.GetIssuer(r.Context()).ID,
+		RemoteAddr: r.RemoteAddr,
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetReports - admin api handler
+func (a APIT) GetReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := a.storage.Reports(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(reports) == 0 {
+		render.NoContent(w, r)
+		return
+	}
+	for i := range reports {
+		reports[i].ShortURL, _ = a.shortURL(reports[i].Key)
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, reports)
+}
+
+// PostDisable - admin api handler, disables link with reason shown instead of redirect.
+// Query parameter domain selects domain id, by default the one serving request Host.
+func (a APIT) PostDisable(w http.ResponseWriter, r *http.Request) {
+	var request model.DisableRequest
+
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"uri":   r.RequestURI,
+			"error": err,
+		}).Warn("json.Decode")
+		http.Error(w, err.Error(), bodyStatus(err))
+		return
+	}
+	key := model.Key{Domain: r.URL.Query().Get("domain"), Code: chi.URLParam(r, "code")}
+	if key.Domain == "" {
+		key.Domain = a.domain(r).ID
+	}
+
+	err = a.storage.SetStatus(r.Context(), key, model.LinkStatus{State: model.StateDisabled, Reason: request.Reason})
+	switch {
+	case errors.Is(err, strg.ErrNotFound):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	a.log(r.Context()).WithFields(map[string]interface{}{
+		"key":    key.String(),
+		"reason": request.Reason,
+	}).Info("link disabled")
+	e := auditEvent(r, model.ActionDisable, key)
+	e.Reason = request.Reason
+	a.addAudit(r.Context(), e)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkSafety asks configured checker about rawURL, failing open when checker is unavailable
+func (a APIT) checkSafety(r *http.Request, rawURL string) safety.Verdict {
+	if a.checker == nil {
+		return safety.Verdict{Safe: true}
+	}
+	v, err := a.checker.Check(r.Context(), rawURL)
+	if err != nil {
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"url":   rawURL,
+			"error": err,
+		}).Warn("safety check failed")
+		return safety.Verdict{Safe: true}
+	}
+	if !v.Safe {
+		a.log(r.Context()).WithFields(map[string]interface{}{
+			"url":    rawURL,
+			"threat": v.Threat,
+		}).Warn("unsafe destination")
+	}
+	return v
+}
+
+var interstitialTmpl = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>Warning: suspicious link</title></head>
+<body>
+<h1>Warning: this link may be unsafe</h1>
+<p>The destination has been flagged{{if .Threat}} as <b>{{.Threat}}</b>{{end}} and is under review.</p>
+<p>Destination: <code>{{.URL}}</code></p>
+<p><a href="{{.URL}}" rel="noopener noreferrer nofollow">Continue at your own risk</a></p>
+</body>
+</html>
+`))
+
+// interstitial writes warning page for quarantined link instead of redirect
+func (a APIT) interstitial(w http.ResponseWriter, r *http.Request, dest, threat string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := interstitialTmpl.Execute(w, struct{ URL, Threat string }{dest, threat}); err != nil {
+		a.log(r.Context()).WithField("error", err).Warn("interstitial template")
+	}
+}
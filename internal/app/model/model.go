@@ -5,8 +5,20 @@ import (
 	"encoding/json"
 	"net/url"
 	"strconv"
+	"time"
 )
 
+// Key - identity of short link, code is unique within domain
+type Key struct {
+	Domain string `json:"domain"`
+	Code   string `json:"code"`
+}
+
+// String - "domain/code"
+func (k Key) String() string {
+	return k.Domain + "/" + k.Code
+}
+
 // Request struct
 type Request struct {
 	URL *url.URL `json:"url"`
@@ -57,3 +69,87 @@ type Batch []struct {
 
 // BatchDelete slice
 type BatchDelete []string
+
+// Link states
+const (
+	StateActive      = ""
+	StateDisabled    = "disabled"
+	StateQuarantined = "quarantined"
+)
+
+// LinkStatus struct
+type LinkStatus struct {
+	State  string `json:"state"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReportRequest struct
+type ReportRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Report - abuse report, ShortURL is filled from Key when report is shown
+type Report struct {
+	Key
+	ShortURL   string    `json:"short_url,omitempty"`
+	Reason     string    `json:"reason"`
+	Reporter   string    `json:"reporter"`
+	RemoteAddr string    `json:"remote_addr"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DisableRequest struct
+type DisableRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Audit actions
+const (
+	ActionCreate  = "create"
+	ActionDelete  = "delete"
+	ActionDisable = "disable"
+	// ActionQuarantine, ActionRelease - link state changed by safety rescan
+	ActionQuarantine = "quarantine"
+	ActionRelease    = "release"
+)
+
+// ActorSystem - actor of audit events caused by the service itself
+const ActorSystem = "system"
+
+// AuditEvent - link lifecycle event, Hash chains it to previous event.
+// OldState and NewState are set for status changes.
+type AuditEvent struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Domain     string    `json:"domain,omitempty"`
+	Code       string    `json:"code"`
+	OldURL     string    `json:"old_url,omitempty"`
+	NewURL     string    `json:"new_url,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	OldState   string    `json:"old_state,omitempty"`
+	NewState   string    `json:"new_state,omitempty"`
+	RemoteAddr string    `json:"remote_addr"`
+	RequestID  string    `json:"request_id,omitempty"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
+}
+
+// AuditFilter - audit query, zero fields match everything
+type AuditFilter struct {
+	Actor  string
+	Domain string
+	Code   string
+	From   time.Time
+	To     time.Time
+}
+
+// Match - reports whether event satisfies filter
+func (f AuditFilter) Match(e AuditEvent) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Domain == "" || e.Domain == f.Domain) &&
+		(f.Code == "" || e.Code == f.Code) &&
+		(f.From.IsZero() || !e.Time.Before(f.From)) &&
+		(f.To.IsZero() || e.Time.Before(f.To))
+}
@@ -0,0 +1,2 @@
+// Package safety - url reputation checkers
+package safety
@@ -0,0 +1,39 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/tracing"
+	"net/http"
+	"time"
+)
+
+// New - creates checker configured by cfg, returns nil when checking is disabled.
+// Cached verdicts are swept until ctx is done.
+func New(ctx context.Context, cfg config.SafetyConfig) (SafetyChecker, error) {
+	var c SafetyChecker
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "http":
+		c = NewHTTPChecker(cfg.Endpoint, cfg.APIKey, &http.Client{
+			Timeout:   time.Duration(cfg.Timeout),
+			Transport: tracing.Transport{},
+		})
+	case "local":
+		lc, err := NewLocalChecker(cfg.LocalPath)
+		if err != nil {
+			return nil, err
+		}
+		c = lc
+	default:
+		return nil, errors.New("unknown safety provider: " + cfg.Provider)
+	}
+	if cfg.CacheTTL > 0 && cfg.CacheSize > 0 {
+		cached := NewCached(c, cfg.CacheSize, time.Duration(cfg.CacheTTL))
+		go cached.Run(ctx, time.Duration(cfg.CacheTTL))
+		c = cached
+	}
+	return c, nil
+}
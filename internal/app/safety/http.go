@@ -0,0 +1,97 @@
+package safety
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DefaultEndpoint - Safe Browsing lookup api
+const DefaultEndpoint = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+
+// HTTPChecker - checker using Safe-Browsing-compatible lookup api
+type HTTPChecker struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+// NewHTTPChecker - constructor, DefaultEndpoint is used when endpoint is empty
+func NewHTTPChecker(endpoint, apiKey string, client *http.Client) *HTTPChecker {
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPChecker{endpoint: endpoint, apiKey: apiKey, client: client}
+}
+
+type threatEntry struct {
+	URL string `json:"url"`
+}
+
+type lookupRequest struct {
+	Client struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+	ThreatInfo struct {
+		ThreatTypes      []string      `json:"threatTypes"`
+		PlatformTypes    []string      `json:"platformTypes"`
+		ThreatEntryTypes []string      `json:"threatEntryTypes"`
+		ThreatEntries    []threatEntry `json:"threatEntries"`
+	} `json:"threatInfo"`
+}
+
+type lookupResponse struct {
+	Matches []struct {
+		ThreatType string      `json:"threatType"`
+		Threat     threatEntry `json:"threat"`
+	} `json:"matches"`
+}
+
+// Check - asks lookup api about rawURL
+func (c *HTTPChecker) Check(ctx context.Context, rawURL string) (Verdict, error) {
+	var request lookupRequest
+	request.Client.ClientID = "shortener"
+	request.Client.ClientVersion = "1.0"
+	request.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	request.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	request.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	request.ThreatInfo.ThreatEntries = []threatEntry{{URL: rawURL}}
+	b, err := json.Marshal(request)
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	endpoint := c.endpoint
+	if c.apiKey != "" {
+		endpoint += "?key=" + url.QueryEscape(c.apiKey)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("safety lookup: unexpected status %d", resp.StatusCode)
+	}
+
+	var response lookupResponse
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Verdict{}, err
+	}
+	if len(response.Matches) > 0 {
+		return Verdict{Threat: response.Matches[0].ThreatType}, nil
+	}
+	return Verdict{Safe: true}, nil
+}
@@ -0,0 +1,120 @@
+package safety
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// LocalChecker - checker using local list of sha256 hash prefixes of url expressions.
+// Each line of list is hex encoded prefix optionally followed by threat type,
+// lines starting with "#" are comments.
+type LocalChecker struct {
+	prefixes map[string]string
+	lengths  []int
+}
+
+// NewLocalChecker - constructor, reads list from file
+func NewLocalChecker(path string) (*LocalChecker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseLocal(f)
+}
+
+// ParseLocal - reads list of hash prefixes from r
+func ParseLocal(r io.Reader) (*LocalChecker, error) {
+	c := &LocalChecker{prefixes: make(map[string]string)}
+	seen := make(map[int]struct{})
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		prefix, threat, _ := strings.Cut(line, " ")
+		prefix = strings.ToLower(prefix)
+		if _, err := hex.DecodeString(prefix); err != nil {
+			return nil, err
+		}
+		threat = strings.TrimSpace(threat)
+		if threat == "" {
+			threat = "THREAT_TYPE_UNSPECIFIED"
+		}
+		c.prefixes[prefix] = threat
+		if _, ok := seen[len(prefix)]; !ok {
+			seen[len(prefix)] = struct{}{}
+			c.lengths = append(c.lengths, len(prefix))
+		}
+	}
+	return c, sc.Err()
+}
+
+// Hash - returns hex encoded sha256 of url expression, helper for building lists
+func Hash(expression string) string {
+	h := sha256.Sum256([]byte(expression))
+	return hex.EncodeToString(h[:])
+}
+
+// Check - matches hashes of rawURL expressions against list
+func (c *LocalChecker) Check(_ context.Context, rawURL string) (Verdict, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Verdict{}, err
+	}
+	for _, e := range expressions(u) {
+		h := Hash(e)
+		for _, l := range c.lengths {
+			if l > len(h) {
+				continue
+			}
+			if threat, ok := c.prefixes[h[:l]]; ok {
+				return Verdict{Threat: threat}, nil
+			}
+		}
+	}
+	return Verdict{Safe: true}, nil
+}
+
+// expressions returns host suffix / path prefix combinations of u the way Safe Browsing builds them
+func expressions(u *url.URL) []string {
+	host := strings.ToLower(u.Hostname())
+	hosts := []string{host}
+	parts := strings.Split(host, ".")
+	for i := len(parts) - 5; i < len(parts)-1; i++ {
+		if i > 0 {
+			hosts = append(hosts, strings.Join(parts[i:], "."))
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	paths := []string{path}
+	if u.RawQuery != "" {
+		paths = append(paths, path+"?"+u.RawQuery)
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	prefix := "/"
+	paths = append(paths, prefix)
+	for i := 0; i < len(segments)-1 && i < 3; i++ {
+		prefix += segments[i] + "/"
+		paths = append(paths, prefix)
+	}
+
+	res := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			res = append(res, h+p)
+		}
+	}
+	return res
+}
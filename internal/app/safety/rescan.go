@@ -0,0 +1,98 @@
+package safety
+
+import (
+	"context"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/logger"
+	"time"
+)
+
+// LinkStore - storage part used by Rescanner
+type LinkStore interface {
+	Range(ctx context.Context, f func(key model.Key, value string) bool)
+	Status(ctx context.Context, key model.Key) model.LinkStatus
+	SetStatus(ctx context.Context, key model.Key, status model.LinkStatus) error
+	AddAuditEvent(ctx context.Context, event model.AuditEvent) error
+}
+
+// Rescanner - periodically rechecks stored links and quarantines unsafe ones
+type Rescanner struct {
+	checker SafetyChecker
+	store   LinkStore
+	logger  logger.Logger
+}
+
+// NewRescanner - constructor
+func NewRescanner(c SafetyChecker, st LinkStore, l logger.Logger) *Rescanner {
+	return &Rescanner{checker: c, store: st, logger: l}
+}
+
+// Run - rescans links every interval until ctx is done
+func (r *Rescanner) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.Scan(ctx)
+		}
+	}
+}
+
+// Scan - checks every stored link once.
+// Unsafe links become quarantined, quarantined links found safe are released.
+// Disabled links are left untouched, changes are audited as done by system.
+func (r *Rescanner) Scan(ctx context.Context) {
+	links := make(map[model.Key]string)
+	r.store.Range(ctx, func(key model.Key, value string) bool {
+		links[key] = value
+		return ctx.Err() == nil
+	})
+	for key, value := range links {
+		if ctx.Err() != nil {
+			return
+		}
+		v, err := r.checker.Check(ctx, value)
+		if err != nil {
+			r.logger.WithField("error", err).Warn("Error while rescan url")
+			continue
+		}
+		st := r.store.Status(ctx, key)
+		var next model.LinkStatus
+		var action string
+		switch {
+		case !v.Safe && st.State == model.StateActive:
+			next = model.LinkStatus{State: model.StateQuarantined, Reason: v.Threat}
+			action = model.ActionQuarantine
+		case v.Safe && st.State == model.StateQuarantined:
+			next = model.LinkStatus{State: model.StateActive}
+			action = model.ActionRelease
+		default:
+			continue
+		}
+		if err = r.store.SetStatus(ctx, key, next); err != nil {
+			r.logger.WithField("error", err).Warn("Error while update link status")
+			continue
+		}
+		r.logger.WithFields(map[string]interface{}{
+			"key":    key.String(),
+			"state":  next.State,
+			"threat": v.Threat,
+		}).Info("link status changed by rescan")
+		err = r.store.AddAuditEvent(ctx, model.AuditEvent{
+			Time:     time.Now(),
+			Actor:    model.ActorSystem,
+			Action:   action,
+			Domain:   key.Domain,
+			Code:     key.Code,
+			Reason:   v.Threat,
+			OldState: st.State,
+			NewState: next.State,
+		})
+		if err != nil {
+			r.logger.WithField("error", err).Error("Error while add audit event")
+		}
+	}
+}
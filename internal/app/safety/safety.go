@@ -0,0 +1,104 @@
+package safety
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Verdict - result of url check
+type Verdict struct {
+	Safe   bool
+	Threat string
+}
+
+// SafetyChecker - url reputation checker
+type SafetyChecker interface {
+	Check(ctx context.Context, rawURL string) (Verdict, error)
+}
+
+type cacheEntry struct {
+	url     string
+	verdict Verdict
+	expires time.Time
+}
+
+// Cached - checker decorator caching verdicts of up to size urls in LRU cache for ttl
+type Cached struct {
+	checker SafetyChecker
+	size    int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// NewCached - constructor, expired verdicts are not returned and are dropped by Run
+func NewCached(c SafetyChecker, size int, ttl time.Duration) *Cached {
+	return &Cached{checker: c, size: size, ttl: ttl, lru: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// Check - returns cached verdict or asks underlying checker
+func (c *Cached) Check(ctx context.Context, rawURL string) (Verdict, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if el, ok := c.entries[rawURL]; ok {
+		e := el.Value.(*cacheEntry)
+		if now.Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return e.verdict, nil
+		}
+	}
+	c.mu.Unlock()
+	v, err := c.checker.Check(ctx, rawURL)
+	if err != nil {
+		return v, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[rawURL]; ok {
+		*el.Value.(*cacheEntry) = cacheEntry{url: rawURL, verdict: v, expires: now.Add(c.ttl)}
+		c.lru.MoveToFront(el)
+		return v, nil
+	}
+	c.entries[rawURL] = c.lru.PushFront(&cacheEntry{url: rawURL, verdict: v, expires: now.Add(c.ttl)})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+	return v, nil
+}
+
+// remove - drops cached entry, called with mu held
+func (c *Cached) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).url)
+}
+
+// Sweep - drops expired verdicts
+func (c *Cached) Sweep() {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, el := range c.entries {
+		if !now.Before(el.Value.(*cacheEntry).expires) {
+			c.remove(el)
+		}
+	}
+}
+
+// Run - sweeps expired verdicts every interval until ctx is done
+func (c *Cached) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		c.Sweep()
+	}
+}
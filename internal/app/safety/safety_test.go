@@ -0,0 +1,174 @@
+package safety
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPChecker_Check(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.URL.Query().Get("key"))
+		var req lookupRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Len(t, req.ThreatInfo.ThreatEntries, 1)
+		switch req.ThreatInfo.ThreatEntries[0].URL {
+		case "http://malware.test/":
+			_, _ = w.Write([]byte(`{"matches":[{"threatType":"MALWARE","threat":{"url":"http://malware.test/"}}]}`))
+		case "http://broken.test/":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+	c := NewHTTPChecker(srv.URL, "secret", nil)
+
+	v, err := c.Check(context.Background(), "https://go.dev/")
+	require.NoError(t, err)
+	assert.True(t, v.Safe)
+
+	v, err = c.Check(context.Background(), "http://malware.test/")
+	require.NoError(t, err)
+	assert.Equal(t, Verdict{Threat: "MALWARE"}, v)
+
+	_, err = c.Check(context.Background(), "http://broken.test/")
+	assert.Error(t, err)
+}
+
+func TestLocalChecker_Check(t *testing.T) {
+	c, err := ParseLocal(strings.NewReader(
+		"# list\n" +
+			Hash("evil.test/")[:8] + " SOCIAL_ENGINEERING\n" +
+			Hash("bad.test/malware/")[:16] + "\n"))
+	require.NoError(t, err)
+	tests := []struct {
+		url    string
+		threat string
+	}{
+		{url: "https://go.dev/", threat: ""},
+		{url: "http://evil.test/", threat: "SOCIAL_ENGINEERING"},
+		{url: "http://www.evil.test/login?x=1", threat: "SOCIAL_ENGINEERING"},
+		{url: "http://bad.test/malware/a/b.exe", threat: "THREAT_TYPE_UNSPECIFIED"},
+		{url: "http://bad.test/other", threat: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			v, err := c.Check(context.Background(), tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.threat == "", v.Safe)
+			assert.Equal(t, tt.threat, v.Threat)
+		})
+	}
+
+	_, err = ParseLocal(strings.NewReader("zz\n"))
+	assert.Error(t, err)
+}
+
+type countingChecker struct {
+	calls int
+	err   error
+}
+
+func (c *countingChecker) Check(context.Context, string) (Verdict, error) {
+	c.calls++
+	return Verdict{Safe: true}, c.err
+}
+
+func TestCached_Check(t *testing.T) {
+	cc := &countingChecker{}
+	c := NewCached(cc, 2, 20*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		_, err := c.Check(context.Background(), "https://go.dev/")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, cc.calls)
+	time.Sleep(30 * time.Millisecond)
+	_, _ = c.Check(context.Background(), "https://go.dev/")
+	assert.Equal(t, 2, cc.calls)
+
+	_, _ = c.Check(context.Background(), "https://go.dev/doc/")
+	_, _ = c.Check(context.Background(), "https://go.dev/blog/")
+	assert.Len(t, c.entries, 2, "least recently used url evicted")
+	_, _ = c.Check(context.Background(), "https://go.dev/")
+	assert.Equal(t, 5, cc.calls)
+	time.Sleep(30 * time.Millisecond)
+	c.Sweep()
+	assert.Empty(t, c.entries, "expired urls swept")
+
+	cc.err = errors.New("unavailable")
+	_, err := c.Check(context.Background(), "https://example.com/")
+	assert.Error(t, err)
+}
+
+type memStore struct {
+	links  map[model.Key]string
+	status map[model.Key]model.LinkStatus
+	audit  []model.AuditEvent
+}
+
+func (m *memStore) Range(_ context.Context, f func(key model.Key, value string) bool) {
+	for k, v := range m.links {
+		if !f(k, v) {
+			return
+		}
+	}
+}
+
+func (m *memStore) Status(_ context.Context, key model.Key) model.LinkStatus { return m.status[key] }
+
+func (m *memStore) SetStatus(_ context.Context, key model.Key, status model.LinkStatus) error {
+	m.status[key] = status
+	return nil
+}
+
+func (m *memStore) AddAuditEvent(_ context.Context, event model.AuditEvent) error {
+	m.audit = append(m.audit, event)
+	return nil
+}
+
+func TestRescanner_Scan(t *testing.T) {
+	c, err := ParseLocal(strings.NewReader(Hash("evil.test/")[:8] + " MALWARE\n"))
+	require.NoError(t, err)
+	st := &memStore{
+		links: map[model.Key]string{
+			{Code: "a"}: "http://evil.test/",
+			{Code: "b"}: "https://go.dev/",
+			{Code: "c"}: "https://example.com/",
+			{Code: "d"}: "http://evil.test/x",
+		},
+		status: map[model.Key]model.LinkStatus{
+			{Code: "c"}: {State: model.StateQuarantined, Reason: "MALWARE"},
+			{Code: "d"}: {State: model.StateDisabled, Reason: "abuse"},
+		},
+	}
+	NewRescanner(c, st, logger.NewDummy()).Scan(context.Background())
+	assert.Equal(t, model.LinkStatus{State: model.StateQuarantined, Reason: "MALWARE"}, st.status[model.Key{Code: "a"}])
+	assert.Equal(t, model.LinkStatus{}, st.status[model.Key{Code: "b"}])
+	assert.Equal(t, model.LinkStatus{}, st.status[model.Key{Code: "c"}])
+	assert.Equal(t, model.LinkStatus{State: model.StateDisabled, Reason: "abuse"}, st.status[model.Key{Code: "d"}])
+
+	require.Len(t, st.audit, 2)
+	events := make(map[string]model.AuditEvent)
+	for _, e := range st.audit {
+		assert.Equal(t, model.ActorSystem, e.Actor)
+		events[e.Code] = e
+	}
+	assert.Equal(t, model.ActionQuarantine, events["a"].Action)
+	assert.Equal(t, "MALWARE", events["a"].Reason)
+	assert.Equal(t, model.StateActive, events["a"].OldState)
+	assert.Equal(t, model.StateQuarantined, events["a"].NewState)
+	assert.Equal(t, model.ActionRelease, events["c"].Action)
+	assert.Equal(t, model.StateQuarantined, events["c"].OldState)
+	assert.Equal(t, model.StateActive, events["c"].NewState)
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/app/bloom"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/Stas9132/shortener/internal/metrics"
+)
+
+// KeysFunc - calls f for every stored key, error means some keys may be missed
+type KeysFunc func(ctx context.Context, f func(key model.Key)) error
+
+// BloomT - storage decorator answering lookups of codes never stored without calling storage.
+// Filter is built from keys, extended on inserts and rebuilt periodically to forget deleted codes.
+// Lookups bypass filter until it is built and while inserts of other instances are not delivered by ChangeListenerT.
+type BloomT struct {
+	StorageI
+	appCtx   context.Context
+	logger   logger.Logger
+	keys     KeysFunc
+	fpRate   float64
+	capacity int
+
+	// rebuildMu serializes builds
+	rebuildMu sync.Mutex
+	mu        sync.RWMutex
+	filter    *bloom.Filter
+	// building - filter being built, receives inserts too
+	building *bloom.Filter
+	// built - last build succeeded
+	built atomic.Bool
+	// following - inserts of other instances are delivered
+	following atomic.Bool
+}
+
+// NewBloom - constructor, filters sized for capacity codes or twice the stored ones with false positive rate fpRate.
+// First filter is built when subscribed ChangeListenerT starts listening.
+func NewBloom(ctx context.Context, l logger.Logger, st StorageI, keys KeysFunc, fpRate float64, capacity int) *BloomT {
+	return &BloomT{StorageI: st, appCtx: ctx, logger: l, keys: keys, fpRate: fpRate, capacity: capacity}
+}
+
+// Rebuild - builds new filter from stored keys and swaps it in, on error keeps filter bypassed
+func (s *BloomT) Rebuild(ctx context.Context) error {
+	s.rebuildMu.Lock()
+	defer s.rebuildMu.Unlock()
+	// size for growth until next rebuild
+	n := s.capacity
+	s.mu.RLock()
+	if s.filter != nil && 2*s.filter.Count() > n {
+		n = 2 * s.filter.Count()
+	}
+	s.mu.RUnlock()
+
+	f := bloom.New(n, s.fpRate)
+	s.mu.Lock()
+	s.building = f
+	s.mu.Unlock()
+	t := time.Now()
+	err := s.keys(ctx, func(key model.Key) { f.Add(key.String()) })
+	s.mu.Lock()
+	s.building = nil
+	if err == nil {
+		s.filter = f
+	}
+	s.mu.Unlock()
+	s.built.Store(err == nil)
+	if err != nil {
+		s.logger.WithField("error", err).Error("Error while build bloom filter, lookups bypass it")
+		return err
+	}
+	metrics.SetBloomFilter(f.Bytes(), f.Count())
+	s.logger.WithFields(map[string]interface{}{
+		"items":    f.Count(),
+		"bytes":    f.Bytes(),
+		"fpRate":   f.FalsePositiveRate(),
+		"duration": time.Since(t).String(),
+	}).Info("Bloom filter built")
+	return nil
+}
+
+// Run - rebuilds filter every interval until ctx is done
+func (s *BloomT) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		s.Rebuild(ctx)
+	}
+}
+
+// add - adds key to filter and to the one being built
+func (s *BloomT) add(key model.Key) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.filter != nil {
+		s.filter.Add(key.String())
+	}
+	if s.building != nil {
+		s.building.Add(key.String())
+	}
+}
+
+// Changed - link changed by other instance, inserted code may be new
+func (s *BloomT) Changed(key model.Key) {
+	s.add(key)
+}
+
+// Listening - inserts of other instances are missed while not listening, after reconnect filter is rebuilt
+func (s *BloomT) Listening(ok bool) {
+	if !ok {
+		s.following.Store(false)
+		return
+	}
+	s.Rebuild(s.appCtx)
+	s.following.Store(true)
+}
+
+// Load - method, codes absent in filter are unknown
+func (s *BloomT) Load(ctx context.Context, key model.Key) (string, bool) {
+	if !s.built.Load() || !s.following.Load() {
+		metrics.ObserveBloom("bypass")
+		return s.StorageI.Load(ctx, key)
+	}
+	s.mu.RLock()
+	f := s.filter
+	s.mu.RUnlock()
+	if !f.MayContain(key.String()) {
+		metrics.ObserveBloom("absent")
+		return "", false
+	}
+	metrics.ObserveBloom("maybe")
+	return s.StorageI.Load(ctx, key)
+}
+
+// Store - method, key is added before it becomes visible in storage
+func (s *BloomT) Store(ctx context.Context, key model.Key, value string) {
+	s.add(key)
+	s.StorageI.Store(ctx, key, value)
+}
+
+// LoadOrStore - method
+func (s *BloomT) LoadOrStore(ctx context.Context, key model.Key, value string) (string, bool) {
+	s.add(key)
+	return s.StorageI.LoadOrStore(ctx, key, value)
+}
+
+// LoadOrStoreExt - method
+func (s *BloomT) LoadOrStoreExt(ctx context.Context, key model.Key, value, user string) (string, bool) {
+	s.add(key)
+	return s.StorageI.LoadOrStoreExt(ctx, key, value, user)
+}
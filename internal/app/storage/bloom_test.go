@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloom(t *testing.T) {
+	ctx := context.Background()
+	backend := newCounting(t)
+	a, b, c := model.Key{Domain: "d", Code: "a"}, model.Key{Domain: "d", Code: "b"}, model.Key{Domain: "d", Code: "c"}
+	backend.Store(ctx, a, "https://go.dev/")
+
+	var keysErr error
+	keys := func(ctx context.Context, f func(key model.Key)) error {
+		if keysErr != nil {
+			return keysErr
+		}
+		backend.Range(ctx, func(key model.Key, value string) bool {
+			f(key)
+			return true
+		})
+		return nil
+	}
+	s := NewBloom(ctx, logger.NewDummy(), backend, keys, 1e-9, 100)
+
+	s.Load(ctx, b)
+	assert.Equal(t, 1, backend.loads, "bypassed until listening")
+
+	s.Listening(true)
+	_, ok := s.Load(ctx, b)
+	assert.False(t, ok)
+	assert.Equal(t, 1, backend.loads, "unknown code answered by filter")
+	v, ok := s.Load(ctx, a)
+	assert.True(t, ok, "stored code from build")
+	assert.Equal(t, "https://go.dev/", v)
+	assert.Equal(t, 2, backend.loads)
+
+	s.Store(ctx, b, "https://go.dev/doc/")
+	_, ok = s.Load(ctx, b)
+	assert.True(t, ok, "inserted code")
+	s.Changed(c)
+	s.Load(ctx, c)
+	assert.Equal(t, 4, backend.loads, "code inserted by other instance")
+
+	s.Delete(ctx, a)
+	s.Load(ctx, a)
+	assert.Equal(t, 5, backend.loads, "deleted code stays until rebuild")
+	assert.NoError(t, s.Rebuild(ctx))
+	s.Load(ctx, a)
+	assert.Equal(t, 5, backend.loads, "rebuild forgets deleted code")
+
+	s.Listening(false)
+	s.Load(ctx, a)
+	assert.Equal(t, 6, backend.loads, "bypassed while changes are not delivered")
+
+	keysErr = errors.New("connection refused")
+	s.Listening(true)
+	s.Load(ctx, a)
+	assert.Equal(t, 7, backend.loads, "bypassed after failed build")
+}
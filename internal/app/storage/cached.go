@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/metrics"
+)
+
+// cacheEntry - cached resolution and status of key, each filled on first lookup
+type cacheEntry struct {
+	key model.Key
+
+	hasValue     bool
+	value        string
+	found        bool
+	valueExpires time.Time
+
+	hasStatus     bool
+	status        model.LinkStatus
+	statusExpires time.Time
+}
+
+// CachedT - storage decorator keeping link resolutions and statuses in bounded LRU cache.
+// Writes through the decorator invalidate affected keys, changes made elsewhere are seen after ttl.
+type CachedT struct {
+	StorageI
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[model.Key]*list.Element
+	// gen - number of invalidations, lookup result is not cached when it changed during backend call
+	gen uint64
+	// maxTTL - cap of ttls while changes of other instances are not delivered, zero means no cap
+	maxTTL time.Duration
+}
+
+// NewCached - constructor, caches up to size keys, found links for ttl and unknown codes for negativeTTL.
+// Zero negativeTTL disables negative caching.
+func NewCached(st StorageI, size int, ttl, negativeTTL time.Duration) *CachedT {
+	return &CachedT{
+		StorageI:    st,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		lru:         list.New(),
+		items:       make(map[model.Key]*list.Element),
+	}
+}
+
+// lookup - cached entry of key moved to front, nil when not cached
+func (s *CachedT) lookup(key model.Key) *cacheEntry {
+	el, ok := s.items[key]
+	if !ok {
+		return nil
+	}
+	s.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+// fill - entry of key for update, nil when invalidation happened since gen was read
+func (s *CachedT) fill(key model.Key, gen uint64) *cacheEntry {
+	if s.gen != gen {
+		return nil
+	}
+	if el, ok := s.items[key]; ok {
+		s.lru.MoveToFront(el)
+		return el.Value.(*cacheEntry)
+	}
+	e := &cacheEntry{key: key}
+	s.items[key] = s.lru.PushFront(e)
+	for s.lru.Len() > s.size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.items, oldest.Value.(*cacheEntry).key)
+	}
+	metrics.SetCacheEntries(s.lru.Len())
+	return e
+}
+
+// Invalidate - drops cached keys, for changes made by other instances
+func (s *CachedT) Invalidate(keys ...model.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	for _, key := range keys {
+		if el, ok := s.items[key]; ok {
+			s.lru.Remove(el)
+			delete(s.items, key)
+		}
+	}
+	metrics.SetCacheEntries(s.lru.Len())
+}
+
+// SetMaxTTL - caps lifetime of entries cached from now on, zero removes the cap
+func (s *CachedT) SetMaxTTL(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxTTL = d
+}
+
+// ttlOf - lifetime of lookup result, called with mu held
+func (s *CachedT) ttlOf(ttl time.Duration) time.Duration {
+	if s.maxTTL > 0 && ttl > s.maxTTL {
+		return s.maxTTL
+	}
+	return ttl
+}
+
+// Purge - drops all cached keys
+func (s *CachedT) Purge() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gen++
+	s.lru.Init()
+	s.items = make(map[model.Key]*list.Element)
+	metrics.SetCacheEntries(0)
+}
+
+// Load - method, unknown codes are cached too
+func (s *CachedT) Load(ctx context.Context, key model.Key) (string, bool) {
+	now := time.Now()
+	s.mu.Lock()
+	if e := s.lookup(key); e != nil && e.hasValue && now.Before(e.valueExpires) {
+		value, found := e.value, e.found
+		s.mu.Unlock()
+		if found {
+			metrics.ObserveCache("load", "hit")
+		} else {
+			metrics.ObserveCache("load", "negative_hit")
+		}
+		return value, found
+	}
+	gen := s.gen
+	s.mu.Unlock()
+	metrics.ObserveCache("load", "miss")
+
+	value, found := s.StorageI.Load(ctx, key)
+	ttl := s.ttl
+	if !found {
+		ttl = s.negativeTTL
+	}
+	if ttl <= 0 {
+		return value, found
+	}
+	s.mu.Lock()
+	if e := s.fill(key, gen); e != nil {
+		e.hasValue, e.value, e.found, e.valueExpires = true, value, found, now.Add(s.ttlOf(ttl))
+	}
+	s.mu.Unlock()
+	return value, found
+}
+
+// Status - method
+func (s *CachedT) Status(ctx context.Context, key model.Key) model.LinkStatus {
+	now := time.Now()
+	s.mu.Lock()
+	if e := s.lookup(key); e != nil && e.hasStatus && now.Before(e.statusExpires) {
+		status := e.status
+		s.mu.Unlock()
+		metrics.ObserveCache("status", "hit")
+		return status
+	}
+	gen := s.gen
+	s.mu.Unlock()
+	metrics.ObserveCache("status", "miss")
+
+	status := s.StorageI.Status(ctx, key)
+	if s.ttl <= 0 {
+		return status
+	}
+	s.mu.Lock()
+	if e := s.fill(key, gen); e != nil {
+		e.hasStatus, e.status, e.statusExpires = true, status, now.Add(s.ttlOf(s.ttl))
+	}
+	s.mu.Unlock()
+	return status
+}
+
+// Store - method
+func (s *CachedT) Store(ctx context.Context, key model.Key, value string) {
+	defer s.Invalidate(key)
+	s.StorageI.Store(ctx, key, value)
+}
+
+// LoadOrStore - method
+func (s *CachedT) LoadOrStore(ctx context.Context, key model.Key, value string) (string, bool) {
+	actual, loaded := s.StorageI.LoadOrStore(ctx, key, value)
+	if !loaded {
+		s.Invalidate(key)
+	}
+	return actual, loaded
+}
+
+// LoadOrStoreExt - method
+func (s *CachedT) LoadOrStoreExt(ctx context.Context, key model.Key, value, user string) (string, bool) {
+	actual, loaded := s.StorageI.LoadOrStoreExt(ctx, key, value, user)
+	if !loaded {
+		s.Invalidate(key)
+	}
+	return actual, loaded
+}
+
+// Delete - method
+func (s *CachedT) Delete(ctx context.Context, keys ...model.Key) {
+	defer s.Invalidate(keys...)
+	s.StorageI.Delete(ctx, keys...)
+}
+
+// SetStatus - method
+func (s *CachedT) SetStatus(ctx context.Context, key model.Key, status model.LinkStatus) error {
+	defer s.Invalidate(key)
+	return s.StorageI.SetStatus(ctx, key, status)
+}
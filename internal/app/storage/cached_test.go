@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingT - storage counting lookups reaching backend
+type countingT struct {
+	StorageI
+	loads, statuses int
+}
+
+func (s *countingT) Load(ctx context.Context, key model.Key) (string, bool) {
+	s.loads++
+	return s.StorageI.Load(ctx, key)
+}
+
+func (s *countingT) Status(ctx context.Context, key model.Key) model.LinkStatus {
+	s.statuses++
+	return s.StorageI.Status(ctx, key)
+}
+
+func newCounting(t *testing.T) *countingT {
+	fs, err := NewFileStorage(context.Background(), logger.NewDummy(), config.Default())
+	require.NoError(t, err)
+	return &countingT{StorageI: fs}
+}
+
+func TestCached(t *testing.T) {
+	ctx := context.Background()
+	backend := newCounting(t)
+	s := NewCached(backend, 2, time.Hour, time.Hour)
+	a, b, c := model.Key{Domain: "d", Code: "a"}, model.Key{Domain: "d", Code: "b"}, model.Key{Domain: "d", Code: "c"}
+
+	_, ok := s.Load(ctx, a)
+	assert.False(t, ok)
+	_, ok = s.Load(ctx, a)
+	assert.False(t, ok)
+	assert.Equal(t, 1, backend.loads, "unknown code cached")
+
+	s.Store(ctx, a, "https://go.dev/")
+	v, ok := s.Load(ctx, a)
+	assert.True(t, ok, "store invalidates negative entry")
+	assert.Equal(t, "https://go.dev/", v)
+	s.Load(ctx, a)
+	assert.Equal(t, 2, backend.loads)
+
+	assert.Equal(t, model.StateActive, s.Status(ctx, a).State)
+	require.NoError(t, s.SetStatus(ctx, a, model.LinkStatus{State: model.StateDisabled, Reason: "spam"}))
+	assert.Equal(t, model.StateDisabled, s.Status(ctx, a).State)
+	s.Status(ctx, a)
+	assert.Equal(t, 2, backend.statuses)
+
+	s.Delete(ctx, a)
+	_, ok = s.Load(ctx, a)
+	assert.False(t, ok, "delete invalidates")
+	assert.Equal(t, 3, backend.loads)
+
+	s = NewCached(backend, 2, time.Hour, time.Hour)
+	backend.loads = 0
+	s.Load(ctx, a)
+	s.Load(ctx, b)
+	s.Load(ctx, a)
+	s.Load(ctx, c)
+	assert.Equal(t, 3, backend.loads)
+	s.Load(ctx, a)
+	assert.Equal(t, 3, backend.loads, "recently used a kept")
+	s.Load(ctx, b)
+	assert.Equal(t, 4, backend.loads, "least recently used b evicted")
+
+	backend.StorageI.Store(ctx, b, "https://go.dev/doc/")
+	_, ok = s.Load(ctx, b)
+	assert.False(t, ok, "change made elsewhere not seen")
+	s.Invalidate(b)
+	_, ok = s.Load(ctx, b)
+	assert.True(t, ok)
+}
+
+func TestCached_TTL(t *testing.T) {
+	ctx := context.Background()
+	backend := newCounting(t)
+	s := NewCached(backend, 10, 20*time.Millisecond, 0)
+	k := model.Key{Domain: "d", Code: "a"}
+
+	s.Load(ctx, k)
+	s.Load(ctx, k)
+	assert.Equal(t, 2, backend.loads, "negative caching disabled")
+
+	backend.StorageI.Store(ctx, k, "https://go.dev/")
+	s.Load(ctx, k)
+	s.Load(ctx, k)
+	assert.Equal(t, 3, backend.loads)
+	time.Sleep(30 * time.Millisecond)
+	s.Load(ctx, k)
+	assert.Equal(t, 4, backend.loads, "expired")
+
+	s.Purge()
+	s.Load(ctx, k)
+	assert.Equal(t, 5, backend.loads)
+}
+
+// staleT - storage changing value while lookup is in flight
+type staleT struct {
+	StorageI
+	during func()
+}
+
+func (s *staleT) Load(ctx context.Context, key model.Key) (string, bool) {
+	v, ok := s.StorageI.Load(ctx, key)
+	s.during()
+	return v, ok
+}
+
+func TestCached_InvalidatedDuringLoad(t *testing.T) {
+	ctx := context.Background()
+	backend := &staleT{StorageI: newCounting(t)}
+	s := NewCached(backend, 10, time.Hour, time.Hour)
+	k := model.Key{Domain: "d", Code: "a"}
+	backend.during = func() { s.Store(ctx, k, "https://go.dev/") }
+
+	_, ok := s.Load(ctx, k)
+	assert.False(t, ok, "value read before store")
+	backend.during = func() {}
+	_, ok = s.Load(ctx, k)
+	assert.True(t, ok, "stale negative result not cached")
+}
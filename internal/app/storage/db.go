@@ -4,8 +4,14 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/app/audit"
+	"github.com/Stas9132/shortener/internal/app/model"
 	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/Stas9132/shortener/internal/metrics"
+	"github.com/Stas9132/shortener/internal/tracing"
+	"time"
 
 	"github.com/golang-migrate/migrate"
 	"github.com/golang-migrate/migrate/database/postgres"
@@ -14,61 +20,206 @@ import (
 	"github.com/jackc/pgerrcode"
 	_ "github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 )
 
+const backendDB = "db"
+
 // DBT struct
 type DBT struct {
 	appCtx context.Context
 	logger logger.Logger
 	db     *sql.DB
 	m      *migrate.Migrate
+	// schema - migration version applied at startup
+	schema uint
+	// readers - replicas serving reads
+	readers *readersT
 }
 
 // NewDB constructor
-func NewDB(ctx context.Context, l logger.Logger) (*DBT, error) {
-	db, err := sql.Open("pgx", config.C.DatabaseDsn)
+func NewDB(ctx context.Context, l logger.Logger, cfg config.Config) (*DBT, error) {
+	db, err := sql.Open("pgx", cfg.DatabaseDsn)
 	if err != nil {
-		logger.WithField("error", err).Errorln("Error while open db")
+		logger.WithField("error", err).Error("Error while open db")
 		return nil, err
 	}
+	if err = metrics.RegisterDB(db); err != nil {
+		logger.WithField("error", err).Warn("Error while register db metrics")
+	}
 
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		logger.WithField("error", err).Errorln("Error while get driver")
+		logger.WithField("error", err).Error("Error while get driver")
 		return nil, err
 	}
 	m, err := migrate.NewWithDatabaseInstance(
 		"file://internal/app/storage/migration",
-		"pgx://"+config.C.DatabaseDsn, driver)
+		"pgx://"+cfg.DatabaseDsn, driver)
 	if err != nil {
-		logger.WithField("error", err).Errorln("Error while create migrate")
+		logger.WithField("error", err).Error("Error while create migrate")
 		return nil, err
 	} else {
 		if err = m.Force(1); err != nil {
-			logger.WithField("error", err).Errorln("Error while migrate force")
+			logger.WithField("error", err).Error("Error while migrate force")
 			return nil, err
 		}
 		if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-			logger.WithField("error", err).Errorln("Error while migrate up")
+			logger.WithField("error", err).Error("Error while migrate up")
 			return nil, err
 		}
 	}
+	schema, _, err := m.Version()
+	if err != nil {
+		logger.WithField("error", err).Error("Error while get migration version")
+		return nil, err
+	}
+	if err = migrateKeys(ctx, db, cfg.DomainList()); err != nil {
+		logger.WithField("error", err).Error("Error while migrate keys")
+		return nil, err
+	}
+	readers, err := newReaders(l, cfg.Replicas)
+	if err != nil {
+		logger.WithField("error", err).Error("Error while open replicas")
+		return nil, err
+	}
+	if len(readers.replicas) > 0 {
+		readers.checkAll(ctx)
+		go readers.Run(ctx, time.Duration(cfg.Replicas.CheckInterval))
+	}
 
 	return &DBT{
-		appCtx: ctx,
-		logger: l,
-		db:     db,
-		m:      m,
+		appCtx:  ctx,
+		logger:  l,
+		db:      db,
+		m:       m,
+		schema:  schema,
+		readers: readers,
 	}, nil
 }
 
+// migrateKeys fills domain_id and code of links and reports stored before domains existed
+func migrateKeys(ctx context.Context, db *sql.DB, domains []config.DomainConfig) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	n := 0
+	for _, table := range []string{"shortener", "reports"} {
+		rows, err := tx.QueryContext(ctx, "SELECT id, short_url FROM "+table+" WHERE code IS NULL")
+		if err != nil {
+			return err
+		}
+		keys := make(map[int64]model.Key)
+		for rows.Next() {
+			var id int64
+			var shortURL string
+			if err = rows.Scan(&id, &shortURL); err != nil {
+				rows.Close()
+				return err
+			}
+			keys[id] = legacyKey(domains, shortURL)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		for id, k := range keys {
+			if _, err = tx.ExecContext(ctx, "UPDATE "+table+" SET domain_id = $2, code = $3 WHERE id = $1", id, k.Domain, k.Code); err != nil {
+				return err
+			}
+		}
+		n += len(keys)
+	}
+	if n > 0 {
+		logger.WithField("records", n).Info("Database migrated to domain keys")
+	}
+	return tx.Commit()
+}
+
+// exec, query, queryRow - wrappers tracing each sql statement
+func (s *DBT) exec(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := tracing.Start(ctx, "sql.exec", semconv.DBSystemPostgreSQL, semconv.DBStatement(query))
+	res, err := s.db.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+	return res, err
+}
+
+// query and queryRow read from replica picked for ctx, failed replica is marked down and read is retried on primary
+func (s *DBT) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	rep, target := s.readers.pick(ctx)
+	if rep != nil {
+		rows, err := queryOn(ctx, rep.db, query, args...)
+		if err == nil || ctx.Err() != nil {
+			metrics.ObserveDBRead(target)
+			return rows, err
+		}
+		s.readers.setHealthy(rep, err)
+		target = "fallback"
+	}
+	metrics.ObserveDBRead(target)
+	return queryOn(ctx, s.db, query, args...)
+}
+
+func (s *DBT) queryRow(ctx context.Context, query string, args ...any) *sql.Row {
+	row, _ := s.queryRowFrom(ctx, query, args...)
+	return row
+}
+
+// queryRowFrom - queryRow also returning replica which served it, nil for primary
+func (s *DBT) queryRowFrom(ctx context.Context, query string, args ...any) (*sql.Row, *replicaT) {
+	rep, target := s.readers.pick(ctx)
+	if rep != nil {
+		row := queryRowOn(ctx, rep.db, query, args...)
+		if row.Err() == nil || ctx.Err() != nil {
+			metrics.ObserveDBRead(target)
+			return row, rep
+		}
+		s.readers.setHealthy(rep, row.Err())
+		target = "fallback"
+	}
+	metrics.ObserveDBRead(target)
+	return queryRowOn(ctx, s.db, query, args...), nil
+}
+
+func queryOn(ctx context.Context, db *sql.DB, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := tracing.Start(ctx, "sql.query", semconv.DBSystemPostgreSQL, semconv.DBStatement(query))
+	rows, err := db.QueryContext(ctx, query, args...)
+	tracing.End(span, err)
+	return rows, err
+}
+
+func queryRowOn(ctx context.Context, db *sql.DB, query string, args ...any) *sql.Row {
+	ctx, span := tracing.Start(ctx, "sql.query", semconv.DBSystemPostgreSQL, semconv.DBStatement(query))
+	row := db.QueryRowContext(ctx, query, args...)
+	tracing.End(span, row.Err())
+	return row
+}
+
+// log returns request scoped logger
+func (s *DBT) log(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, s.logger)
+}
+
 // Load - method
-func (s *DBT) Load(key string) (value string, ok bool) {
+func (s *DBT) Load(ctx context.Context, key model.Key) (value string, ok bool) {
+	const query = "SELECT original_url, is_deleted FROM shortener WHERE domain_id = $1 AND code = $2"
 	var b *bool
-	err := s.db.QueryRowContext(s.appCtx, "SELECT original_url, is_deleted FROM shortener WHERE short_url = $1", key).
-		Scan(&value, &b)
+	t := time.Now()
+	row, rep := s.queryRowFrom(ctx, query, key.Domain, key.Code)
+	err := row.Scan(&value, &b)
+	if rep != nil && errors.Is(err, sql.ErrNoRows) {
+		// lagging replica misses just created links, unknown codes are confirmed by primary
+		err = s.queryRow(withPrimary(ctx), query, key.Domain, key.Code).Scan(&value, &b)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		metrics.ObserveStorage(backendDB, "load", t, nil)
+	} else {
+		metrics.ObserveStorage(backendDB, "load", t, err)
+	}
 	if err != nil {
-		s.logger.WithField("error", err).Errorln("error load()")
+		s.log(ctx).WithField("error", err).Error("error load()")
 		return "", false
 	}
 	if b == nil || !*b {
@@ -78,50 +229,58 @@ func (s *DBT) Load(key string) (value string, ok bool) {
 }
 
 // StoreExt - method
-func (s *DBT) StoreExt(key, value, user string) {
-	_, err := s.db.ExecContext(s.appCtx, "INSERT INTO shortener(short_url,original_url, user_id) values ($1, $2, $3)", key, value, user)
+func (s *DBT) StoreExt(ctx context.Context, key model.Key, value, user string) {
+	t := time.Now()
+	_, err := s.exec(ctx, "INSERT INTO shortener(domain_id, code, original_url, user_id) values ($1, $2, $3, $4)", key.Domain, key.Code, value, user)
 
 	if e, ok := err.(*pgconn.PgError); ok && e.Code == pgerrcode.UniqueViolation {
-		s.logger.WithField("URL", value).Info("URL already exist")
-	} else if err != nil {
-		s.logger.WithField("error", err).
-			Warningln("Error while insert data")
+		metrics.ObserveStorage(backendDB, "store", t, nil)
+		s.log(ctx).WithField("URL", value).Info("URL already exist")
+	} else if metrics.ObserveStorage(backendDB, "store", t, err); err != nil {
+		s.log(ctx).WithField("error", err).
+			Warn("Error while insert data")
 	}
 }
 
 // Store - method
-func (s *DBT) Store(key, value string) {
-	s.StoreExt(key, value, uuid.NewString())
+func (s *DBT) Store(ctx context.Context, key model.Key, value string) {
+	s.StoreExt(ctx, key, value, uuid.NewString())
 }
 
 // LoadOrStore - method
-func (s *DBT) LoadOrStore(key, value string) (actual string, loaded bool) {
-	actual, loaded = s.Load(key)
-	s.Store(key, value)
+func (s *DBT) LoadOrStore(ctx context.Context, key model.Key, value string) (actual string, loaded bool) {
+	actual, loaded = s.Load(withPrimary(ctx), key)
+	s.Store(ctx, key, value)
 	return
 }
 
-// LoadOrStoreExt - method
-func (s *DBT) LoadOrStoreExt(key, value, user string) (actual string, loaded bool) {
-	actual, loaded = s.Load(key)
-	s.StoreExt(key, value, user)
+// LoadOrStoreExt - method, user creating link reads from primary for a while
+func (s *DBT) LoadOrStoreExt(ctx context.Context, key model.Key, value, user string) (actual string, loaded bool) {
+	actual, loaded = s.Load(withPrimary(ctx), key)
+	s.StoreExt(ctx, key, value, user)
+	if !loaded {
+		s.readers.pin(user)
+	}
 	return
 }
 
 // Range - method
-func (s *DBT) Range(f func(key, value string) bool) {
-	rows, err := s.db.QueryContext(s.appCtx, "SELECT short_url, original_url FROM shortener")
+func (s *DBT) Range(ctx context.Context, f func(key model.Key, value string) bool) {
+	t := time.Now()
+	rows, err := s.query(ctx, "SELECT domain_id, code, original_url FROM shortener")
+	metrics.ObserveStorage(backendDB, "range", t, err)
 	if err != nil || rows.Err() != nil {
-		s.logger.WithField("error", err).
-			Warningln("Error while select data")
+		s.log(ctx).WithField("error", err).
+			Warn("Error while select data")
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var key, value string
-		err = rows.Scan(&key, &value)
+		var key model.Key
+		var value string
+		err = rows.Scan(&key.Domain, &key.Code, &value)
 		if err != nil {
-			s.logger.WithField("error", err).
-				Warningln("Error while select data")
+			s.log(ctx).WithField("error", err).
+				Warn("Error while select data")
 		}
 		if !f(key, value) {
 			break
@@ -129,20 +288,42 @@ func (s *DBT) Range(f func(key, value string) bool) {
 	}
 }
 
+// Keys - calls f for key of every link not deleted, unlike Range reports errors
+func (s *DBT) Keys(ctx context.Context, f func(key model.Key)) (err error) {
+	defer func(t time.Time) { metrics.ObserveStorage(backendDB, "keys", t, err) }(time.Now())
+	// replicas may miss keys inserted before notifications of them are listened for
+	rows, err := s.query(withPrimary(ctx), "SELECT domain_id, code FROM shortener WHERE is_deleted IS NOT TRUE")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key model.Key
+		if err = rows.Scan(&key.Domain, &key.Code); err != nil {
+			return err
+		}
+		f(key)
+	}
+	return rows.Err()
+}
+
 // RangeExt - method
-func (s *DBT) RangeExt(f func(key, value, user string) bool) {
-	rows, err := s.db.QueryContext(s.appCtx, "SELECT short_url, original_url, user_id FROM shortener")
+func (s *DBT) RangeExt(ctx context.Context, f func(key model.Key, value, user string) bool) {
+	t := time.Now()
+	rows, err := s.query(ctx, "SELECT domain_id, code, original_url, user_id FROM shortener")
+	metrics.ObserveStorage(backendDB, "range", t, err)
 	if err != nil || rows.Err() != nil {
-		s.logger.WithField("error", err).
-			Warningln("Error while select data")
+		s.log(ctx).WithField("error", err).
+			Warn("Error while select data")
 	}
 	defer rows.Close()
 	for rows.Next() {
-		var key, value, userID string
-		err = rows.Scan(&key, &value, &userID)
+		var key model.Key
+		var value, userID string
+		err = rows.Scan(&key.Domain, &key.Code, &value, &userID)
 		if err != nil {
-			s.logger.WithField("error", err).
-				Warningln("Error while select data")
+			s.log(ctx).WithField("error", err).
+				Warn("Error while select data")
 		}
 		if !f(key, value, userID) {
 			break
@@ -152,22 +333,180 @@ func (s *DBT) RangeExt(f func(key, value, user string) bool) {
 }
 
 // Delete - method
-func (s *DBT) Delete(keys ...string) {
+func (s *DBT) Delete(ctx context.Context, keys ...model.Key) {
 	for _, key := range keys {
-		_, err := s.db.ExecContext(s.appCtx, "update shortener set is_deleted = true where short_url = $1", key)
+		t := time.Now()
+		_, err := s.exec(ctx, "update shortener set is_deleted = true where domain_id = $1 and code = $2", key.Domain, key.Code)
+		metrics.ObserveStorage(backendDB, "delete", t, err)
 		if err != nil {
-			s.logger.WithField("error", err).Errorln("error while db records mark as deleted")
+			s.log(ctx).WithField("error", err).Error("error while db records mark as deleted")
 		}
 	}
 }
 
+// CheckMigrations - health check, schema is at version applied at startup and no migration is half done
+func (s *DBT) CheckMigrations(ctx context.Context) error {
+	var (
+		version int64
+		dirty   bool
+	)
+	if err := s.db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations").Scan(&version, &dirty); err != nil {
+		return err
+	}
+	switch {
+	case dirty:
+		return fmt.Errorf("migration %d is dirty", version)
+	case version != int64(s.schema):
+		return fmt.Errorf("schema version %d, want %d", version, s.schema)
+	}
+	return nil
+}
+
 // Close - method
 func (s *DBT) Close() error {
 	//return errors.Join(s.db.Close(), s.m.Down())
-	return s.db.Close()
+	return errors.Join(s.db.Close(), s.readers.Close())
 }
 
 // Ping - method
-func (s *DBT) Ping() error {
-	return s.db.Ping()
+func (s *DBT) Ping(ctx context.Context) error {
+	t := time.Now()
+	err := s.db.PingContext(ctx)
+	metrics.ObserveStorage(backendDB, "ping", t, err)
+	return err
+}
+
+// SetStatus - method
+func (s *DBT) SetStatus(ctx context.Context, key model.Key, status model.LinkStatus) error {
+	t := time.Now()
+	res, err := s.exec(ctx, "UPDATE shortener SET status = $3, status_reason = $4 WHERE domain_id = $1 AND code = $2",
+		key.Domain, key.Code, status.State, status.Reason)
+	metrics.ObserveStorage(backendDB, "set_status", t, err)
+	if err != nil {
+		s.log(ctx).WithField("error", err).Error("error while update status")
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Status - method
+func (s *DBT) Status(ctx context.Context, key model.Key) (status model.LinkStatus) {
+	t := time.Now()
+	err := s.queryRow(ctx, "SELECT status, status_reason FROM shortener WHERE domain_id = $1 AND code = $2", key.Domain, key.Code).
+		Scan(&status.State, &status.Reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	metrics.ObserveStorage(backendDB, "status", t, err)
+	if err != nil {
+		s.log(ctx).WithField("error", err).Error("error load status")
+	}
+	return
+}
+
+// AddReport - method
+func (s *DBT) AddReport(ctx context.Context, report model.Report) error {
+	t := time.Now()
+	_, err := s.exec(ctx, "INSERT INTO reports(domain_id, code, reason, reporter, remote_addr, created_at) values ($1, $2, $3, $4, $5, $6)",
+		report.Domain, report.Code, report.Reason, report.Reporter, report.RemoteAddr, report.CreatedAt)
+	metrics.ObserveStorage(backendDB, "add_report", t, err)
+	if err != nil {
+		s.log(ctx).WithField("error", err).Error("error while insert report")
+	}
+	return err
+}
+
+// Reports - method
+func (s *DBT) Reports(ctx context.Context) ([]model.Report, error) {
+	t := time.Now()
+	rows, err := s.query(ctx, "SELECT domain_id, code, reason, reporter, remote_addr, created_at FROM reports ORDER BY id")
+	metrics.ObserveStorage(backendDB, "reports", t, err)
+	if err != nil {
+		s.log(ctx).WithField("error", err).Warn("Error while select reports")
+		return nil, err
+	}
+	defer rows.Close()
+	var res []model.Report
+	for rows.Next() {
+		var r model.Report
+		if err = rows.Scan(&r.Domain, &r.Code, &r.Reason, &r.Reporter, &r.RemoteAddr, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// auditLockID - advisory lock key serializing appends to audit chain
+const auditLockID = 0x6175646974
+
+// AddAuditEvent - method, appends event to hash chain under transaction scoped advisory lock
+func (s *DBT) AddAuditEvent(ctx context.Context, event model.AuditEvent) (err error) {
+	t := time.Now()
+	defer func() { metrics.ObserveStorage(backendDB, "add_audit_event", t, err) }()
+	ctx, span := tracing.Start(ctx, "sql.tx", semconv.DBSystemPostgreSQL)
+	defer func() { tracing.End(span, err) }()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.log(ctx).WithField("error", err).Error("error while begin tx")
+		return err
+	}
+	defer tx.Rollback()
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditLockID); err != nil {
+		s.log(ctx).WithField("error", err).Error("error while lock audit log")
+		return err
+	}
+	var id int64
+	var prev string
+	err = tx.QueryRowContext(ctx, "SELECT id, hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&id, &prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.log(ctx).WithField("error", err).Error("error while select audit log head")
+		return err
+	}
+	e := audit.Seal(event, id+1, prev)
+	_, err = tx.ExecContext(ctx, "INSERT INTO audit_log(id, created_at, actor, action, domain_id, code, old_url, new_url, reason, old_state, new_state, remote_addr, request_id, prev_hash, hash) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)",
+		e.ID, e.Time, e.Actor, e.Action, e.Domain, e.Code, e.OldURL, e.NewURL, e.Reason, e.OldState, e.NewState, e.RemoteAddr, e.RequestID, e.PrevHash, e.Hash)
+	if err != nil {
+		s.log(ctx).WithField("error", err).Error("error while insert audit event")
+		return err
+	}
+	return tx.Commit()
+}
+
+// AuditEvents - method
+func (s *DBT) AuditEvents(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	t := time.Now()
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+	rows, err := s.query(ctx, `SELECT id, created_at, actor, action, domain_id, code, old_url, new_url, reason, old_state, new_state, remote_addr, request_id, prev_hash, hash
+FROM audit_log
+WHERE ($1 = '' OR actor = $1) AND ($2 = '' OR code = $2) AND ($5 = '' OR domain_id = $5)
+  AND ($3::timestamptz IS NULL OR created_at >= $3) AND ($4::timestamptz IS NULL OR created_at < $4)
+ORDER BY id`, filter.Actor, filter.Code, from, to, filter.Domain)
+	metrics.ObserveStorage(backendDB, "audit_events", t, err)
+	if err != nil {
+		s.log(ctx).WithField("error", err).Warn("Error while select audit log")
+		return nil, err
+	}
+	defer rows.Close()
+	var res []model.AuditEvent
+	for rows.Next() {
+		var e model.AuditEvent
+		if err = rows.Scan(&e.ID, &e.Time, &e.Actor, &e.Action, &e.Domain, &e.Code, &e.OldURL, &e.NewURL,
+			&e.Reason, &e.OldState, &e.NewState, &e.RemoteAddr, &e.RequestID, &e.PrevHash, &e.Hash); err != nil {
+			return nil, err
+		}
+		e.Time = e.Time.UTC()
+		res = append(res, e)
+	}
+	return res, rows.Err()
 }
@@ -3,159 +3,455 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/app/audit"
+	"github.com/Stas9132/shortener/internal/app/model"
 	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/Stas9132/shortener/internal/metrics"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
+const backendFile = "file"
+
 // FileStorageT - struct
 type FileStorageT struct {
 	appCtx context.Context
 	logger logger.Logger
-	cache  map[string]FileStorageRecordT
-	file   *os.File
+	// mu guards cache, storage file and reports, background rescans share them with request handlers
+	mu      sync.RWMutex
+	cache   map[model.Key]FileStorageRecordT
+	path    string
+	file    *os.File
+	reports []model.Report
+	auditMu sync.Mutex
+	audit   []model.AuditEvent
 }
 
-// NewFileStorage - constructor
-func NewFileStorage(ctx context.Context, l logger.Logger) (*FileStorageT, error) {
-	c := make(map[string]FileStorageRecordT)
+// NewFileStorage - constructor, empty cfg.FileStoragePath keeps data in memory only.
+// Records and reports keyed by full short url are converted to domain and code and written back.
+func NewFileStorage(ctx context.Context, l logger.Logger, cfg config.Config) (*FileStorageT, error) {
+	c := make(map[model.Key]FileStorageRecordT)
+	domains := cfg.DomainList()
 	var f *os.File
+	path := cfg.FileStoragePath
 
-	if config.C.FileStoragePath != "" {
+	if path != "" {
 		var err error
-		f, err = os.OpenFile(config.C.FileStoragePath, os.O_CREATE|os.O_RDWR, 0644)
-		if err != nil && len(config.C.FileStoragePath) > 0 {
-			logger.WithField("error", err).Errorln("Error while open file")
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			logger.WithField("error", err).Error("Error while open file")
 			return nil, err
 		}
 		var fd []FileStorageRecordT
 		if err = json.NewDecoder(f).Decode(&fd); err != nil && err.Error() != "EOF" && f != nil {
-			logger.WithField("error", err).Errorln("Error while unmarshal json")
+			logger.WithField("error", err).Error("Error while unmarshal json")
 			return nil, err
 		}
-		for _, record := range fd {
-			c[record.ShortURL] = record
+		converted := false
+		for i, record := range fd {
+			if record.Code == "" && record.ShortURL != "" {
+				k := legacyKey(domains, record.ShortURL)
+				record.Domain, record.Code, record.ShortURL = k.Domain, k.Code, ""
+				fd[i] = record
+				converted = true
+			}
+			c[record.key()] = record
 		}
+		if converted {
+			if err = rewrite(f, fd); err != nil {
+				logger.WithField("error", err).Error("Error while migrate storage file")
+				return nil, err
+			}
+			logger.WithField("records", len(fd)).Info("Storage file migrated to domain keys")
+		}
+	}
+	reports, err := loadLines[model.Report](path, ".reports")
+	if err != nil {
+		logger.WithField("error", err).Error("Error while load reports")
+		return nil, err
+	}
+	if err = migrateReports(path, domains, reports); err != nil {
+		logger.WithField("error", err).Error("Error while migrate reports")
+		return nil, err
+	}
+	events, err := loadLines[model.AuditEvent](path, ".audit")
+	if err != nil {
+		logger.WithField("error", err).Error("Error while load audit log")
+		return nil, err
+	}
+	if err = audit.Verify(events); err != nil {
+		logger.WithField("error", err).Error("Audit log verification failed")
 	}
 	return &FileStorageT{
-		appCtx: ctx,
-		logger: l,
-		cache:  c,
-		file:   f,
+		appCtx:  ctx,
+		logger:  l,
+		cache:   c,
+		path:    path,
+		file:    f,
+		reports: reports,
+		audit:   events,
 	}, nil
 }
 
+// rewrite replaces content of storage file with records
+func rewrite(f *os.File, fd []FileStorageRecordT) error {
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	return json.NewEncoder(f).Encode(fd)
+}
+
+// migrateReports converts reports keyed by full short url in place and rewrites reports file when any changed
+func migrateReports(path string, domains []config.DomainConfig, reports []model.Report) error {
+	converted := false
+	for i := range reports {
+		if reports[i].Code == "" && reports[i].ShortURL != "" {
+			reports[i].Key, reports[i].ShortURL = legacyKey(domains, reports[i].ShortURL), ""
+			converted = true
+		}
+	}
+	if !converted {
+		return nil
+	}
+	tmp := path + ".reports.tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, r := range reports {
+		if err = enc.Encode(r); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path+".reports")
+}
+
+// log returns request scoped logger
+func (s *FileStorageT) log(ctx context.Context) logger.Logger {
+	return logger.FromContext(ctx, s.logger)
+}
+
 // Load - method
-func (s *FileStorageT) Load(key string) (string, bool) {
+func (s *FileStorageT) Load(ctx context.Context, key model.Key) (string, bool) {
+	defer metrics.ObserveStorage(backendFile, "load", time.Now(), nil)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	value, ok := s.cache[key]
 	return value.OriginalURL, ok
 }
 
 // Store - method
-func (s *FileStorageT) Store(key, value string) {
-	s.StoreExt(key, value, uuid.NewString())
+func (s *FileStorageT) Store(ctx context.Context, key model.Key, value string) {
+	s.StoreExt(ctx, key, value, uuid.NewString())
 }
 
 // StoreExt - method
-func (s *FileStorageT) StoreExt(key, value, user string) {
-	s.cache[key] = FileStorageRecordT{OriginalURL: value, UUID: user}
+func (s *FileStorageT) StoreExt(ctx context.Context, key model.Key, value, user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.storeExt(ctx, key, value, user)
+}
+
+// storeExt - stores record, called with mu held
+func (s *FileStorageT) storeExt(ctx context.Context, key model.Key, value, user string) {
+	var err error
+	defer func(t time.Time) { metrics.ObserveStorage(backendFile, "store", t, err) }(time.Now())
+	record := FileStorageRecordT{UUID: user, Domain: key.Domain, Code: key.Code, OriginalURL: value}
+	s.cache[key] = record
 	if s.file != nil {
-		if _, err := s.file.Seek(0, 0); err != nil {
-			s.logger.WithField("error", err).Errorln("Error while seek file")
+		if _, err = s.file.Seek(0, 0); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while seek file")
 		}
 		var fd []FileStorageRecordT
-		if err := json.NewDecoder(s.file).Decode(&fd); err != nil {
-			s.logger.WithField("error", err).Errorln("Error while unmarshal json")
+		if err = json.NewDecoder(s.file).Decode(&fd); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while unmarshal json")
 		}
-		fd = append(fd, FileStorageRecordT{
-			UUID:        user,
-			ShortURL:    key,
-			OriginalURL: value,
-		})
-		if _, err := s.file.Seek(0, 0); err != nil {
-			s.logger.WithField("error", err).Errorln("Error while seek file")
+		fd = append(fd, record)
+		if _, err = s.file.Seek(0, 0); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while seek file")
 		}
-		if err := json.NewEncoder(s.file).Encode(fd); err != nil {
-			s.logger.WithField("error", err).Errorln("Error while marshal json")
+		if err = json.NewEncoder(s.file).Encode(fd); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while marshal json")
 		}
 	}
 }
 
 // LoadOrStore - method
-func (s *FileStorageT) LoadOrStore(key, value string) (actual string, loaded bool) {
-	actual, loaded = s.Load(key)
-	s.Store(key, value)
-	return
+func (s *FileStorageT) LoadOrStore(ctx context.Context, key model.Key, value string) (actual string, loaded bool) {
+	return s.LoadOrStoreExt(ctx, key, value, uuid.NewString())
 }
 
 // LoadOrStoreExt - method
-func (s *FileStorageT) LoadOrStoreExt(key, value, user string) (actual string, loaded bool) {
-	actual, loaded = s.Load(key)
-	s.StoreExt(key, value, user)
-	return
+func (s *FileStorageT) LoadOrStoreExt(ctx context.Context, key model.Key, value, user string) (actual string, loaded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.cache[key]; ok {
+		return record.OriginalURL, true
+	}
+	s.storeExt(ctx, key, value, user)
+	return "", false
+}
+
+// records - copy of cache, f of Range may call storage
+func (s *FileStorageT) records() []FileStorageRecordT {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]FileStorageRecordT, 0, len(s.cache))
+	for _, v := range s.cache {
+		res = append(res, v)
+	}
+	return res
 }
 
 // RangeExt - method
-func (s *FileStorageT) RangeExt(f func(key, value, user string) bool) {
-	for k, v := range s.cache {
-		if !f(k, v.OriginalURL, v.UUID) {
+func (s *FileStorageT) RangeExt(ctx context.Context, f func(key model.Key, value, user string) bool) {
+	for _, v := range s.records() {
+		if !f(v.key(), v.OriginalURL, v.UUID) {
 			break
 		}
 	}
 }
 
 // Range - method
-func (s *FileStorageT) Range(f func(key, value string) bool) {
-	for k, v := range s.cache {
-		if !f(k, v.OriginalURL) {
+func (s *FileStorageT) Range(ctx context.Context, f func(key model.Key, value string) bool) {
+	for _, v := range s.records() {
+		if !f(v.key(), v.OriginalURL) {
 			break
 		}
 	}
 }
 
-// Close - method
+// Close - flushes storage file to disk and closes it
 func (s *FileStorageT) Close() error {
-	return s.file.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	return errors.Join(s.file.Sync(), s.file.Close())
 }
 
 // Ping - method
-func (s *FileStorageT) Ping() error {
+func (s *FileStorageT) Ping(ctx context.Context) error {
 	return nil
 }
 
+// CheckWritable - health check, storage file exists and its directory accepts new files
+func (s *FileStorageT) CheckWritable(ctx context.Context) error {
+	if s.path == "" {
+		return nil
+	}
+	if _, err := os.Stat(s.path); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(s.path), ".health-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write([]byte("ok"))
+	return errors.Join(err, f.Close(), os.Remove(f.Name()))
+}
+
 // Delete - method
-func (s *FileStorageT) Delete(keys ...string) {
+func (s *FileStorageT) Delete(ctx context.Context, keys ...model.Key) {
+	var err error
+	defer func(t time.Time) { metrics.ObserveStorage(backendFile, "delete", t, err) }(time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	for _, key := range keys {
 		delete(s.cache, key)
-		if _, err := s.file.Seek(0, 0); err != nil {
-			s.logger.WithField("error", err).Errorln("Error while seek file")
+		if _, err = s.file.Seek(0, 0); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while seek file")
 		}
 		var tfd, fd []FileStorageRecordT
-		if err := json.NewDecoder(s.file).Decode(&fd); err != nil {
-			s.logger.WithField("error", err).Errorln("Error while unmarshal json")
+		if err = json.NewDecoder(s.file).Decode(&fd); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while unmarshal json")
 		}
 
 		for _, t := range fd {
-			if t.ShortURL != key {
+			if t.key() != key {
 				tfd = append(tfd, t)
 			}
 		}
 		fd = tfd
-		if _, err := s.file.Seek(0, 0); err != nil {
-			s.logger.WithField("error", err).Errorln("Error while seek file")
+		if _, err = s.file.Seek(0, 0); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while seek file")
+		}
+		if err = json.NewEncoder(s.file).Encode(fd); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while marshal json")
+		}
+	}
+}
+
+// SetStatus - method
+func (s *FileStorageT) SetStatus(ctx context.Context, key model.Key, status model.LinkStatus) (err error) {
+	defer func(t time.Time) { metrics.ObserveStorage(backendFile, "set_status", t, err) }(time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.cache[key]
+	if !ok {
+		return ErrNotFound
+	}
+	record.Status, record.StatusReason = status.State, status.Reason
+	s.cache[key] = record
+	if s.file == nil {
+		return nil
+	}
+	if _, err := s.file.Seek(0, 0); err != nil {
+		s.log(ctx).WithField("error", err).Error("Error while seek file")
+		return err
+	}
+	var fd []FileStorageRecordT
+	if err := json.NewDecoder(s.file).Decode(&fd); err != nil {
+		s.log(ctx).WithField("error", err).Error("Error while unmarshal json")
+		return err
+	}
+	for i := range fd {
+		if fd[i].key() == key {
+			fd[i].Status, fd[i].StatusReason = status.State, status.Reason
+		}
+	}
+	if _, err := s.file.Seek(0, 0); err != nil {
+		s.log(ctx).WithField("error", err).Error("Error while seek file")
+		return err
+	}
+	if err := json.NewEncoder(s.file).Encode(fd); err != nil {
+		s.log(ctx).WithField("error", err).Error("Error while marshal json")
+		return err
+	}
+	return nil
+}
+
+// Status - method
+func (s *FileStorageT) Status(ctx context.Context, key model.Key) model.LinkStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record := s.cache[key]
+	return model.LinkStatus{State: record.Status, Reason: record.StatusReason}
+}
+
+// AddReport - method
+func (s *FileStorageT) AddReport(ctx context.Context, report model.Report) (err error) {
+	defer func(t time.Time) { metrics.ObserveStorage(backendFile, "add_report", t, err) }(time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports = append(s.reports, report)
+	if s.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(s.path+".reports", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		s.log(ctx).WithField("error", err).Error("Error while open reports file")
+		return err
+	}
+	defer f.Close()
+	if err = json.NewEncoder(f).Encode(report); err != nil {
+		s.log(ctx).WithField("error", err).Error("Error while marshal json")
+	}
+	return err
+}
+
+// Reports - method
+func (s *FileStorageT) Reports(ctx context.Context) ([]model.Report, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]model.Report(nil), s.reports...), nil
+}
+
+// AddAuditEvent - method, appends event to hash chain kept next to the storage file one json object per line
+func (s *FileStorageT) AddAuditEvent(ctx context.Context, event model.AuditEvent) (err error) {
+	defer func(t time.Time) { metrics.ObserveStorage(backendFile, "add_audit_event", t, err) }(time.Now())
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	var id int64
+	var prev string
+	if n := len(s.audit); n > 0 {
+		id, prev = s.audit[n-1].ID, s.audit[n-1].Hash
+	}
+	e := audit.Seal(event, id+1, prev)
+	if s.path != "" {
+		f, err := os.OpenFile(s.path+".audit", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while open audit file")
+			return err
+		}
+		defer f.Close()
+		if err = json.NewEncoder(f).Encode(e); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while marshal json")
+			return err
 		}
-		if err := json.NewEncoder(s.file).Encode(fd); err != nil {
-			s.logger.WithField("error", err).Errorln("Error while marshal json")
+		if err = f.Sync(); err != nil {
+			s.log(ctx).WithField("error", err).Error("Error while sync audit file")
+			return err
+		}
+	}
+	s.audit = append(s.audit, e)
+	return nil
+}
+
+// AuditEvents - method
+func (s *FileStorageT) AuditEvents(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	var res []model.AuditEvent
+	for _, e := range s.audit {
+		if filter.Match(e) {
+			res = append(res, e)
 		}
 	}
+	return res, nil
 }
 
-// FileStorageRecordT - type
+// loadLines reads records stored next to the storage file one json object per line
+func loadLines[T any](path, suffix string) ([]T, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path + suffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res []T
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var r T
+		if err = dec.Decode(&r); err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// FileStorageRecordT - type, ShortURL is set only in records written before domains existed
 type FileStorageRecordT struct {
-	UUID        string `json:"uuid"`
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	UUID         string `json:"uuid"`
+	Domain       string `json:"domain"`
+	Code         string `json:"code"`
+	ShortURL     string `json:"short_url,omitempty"`
+	OriginalURL  string `json:"original_url"`
+	Status       string `json:"status,omitempty"`
+	StatusReason string `json:"status_reason,omitempty"`
+}
+
+func (r FileStorageRecordT) key() model.Key {
+	return model.Key{Domain: r.Domain, Code: r.Code}
 }
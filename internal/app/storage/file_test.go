@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/app/safety"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage_RescanWhileStoring(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+	fs, err := NewFileStorage(ctx, logger.NewDummy(), cfg)
+	require.NoError(t, err)
+	defer fs.Close()
+	c, err := safety.ParseLocal(strings.NewReader(safety.Hash("evil.test/")[:8] + " MALWARE\n"))
+	require.NoError(t, err)
+	r := safety.NewRescanner(c, fs, logger.NewDummy())
+
+	const n = 100
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			fs.Store(ctx, model.Key{Domain: "d", Code: fmt.Sprint("s", i)}, "http://evil.test/")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			fs.LoadOrStoreExt(ctx, model.Key{Domain: "d", Code: fmt.Sprint("u", i)}, "https://go.dev/", "user")
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		r.Scan(ctx)
+	}
+	wg.Wait()
+	r.Scan(ctx)
+
+	assert.Equal(t, model.StateQuarantined, fs.Status(ctx, model.Key{Domain: "d", Code: "s0"}).State)
+	assert.Equal(t, model.StateActive, fs.Status(ctx, model.Key{Domain: "d", Code: "u0"}).State)
+	reopened, err := NewFileStorage(ctx, logger.NewDummy(), cfg)
+	require.NoError(t, err)
+	defer reopened.Close()
+	count := 0
+	reopened.Range(ctx, func(model.Key, string) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 2*n, count, "file holds every stored record")
+}
+
+func TestFileStorage_Reports(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.Default()
+	cfg.FileStoragePath = filepath.Join(t.TempDir(), "storage.json")
+	fs, err := NewFileStorage(ctx, logger.NewDummy(), cfg)
+	require.NoError(t, err)
+	defer fs.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, fs.AddReport(ctx, model.Report{Key: model.Key{Domain: "d", Code: fmt.Sprint(i)}, Reason: "spam"}))
+			fs.Reports(ctx)
+		}(i)
+	}
+	wg.Wait()
+	reports, err := fs.Reports(ctx)
+	require.NoError(t, err)
+	assert.Len(t, reports, 20)
+	reports[0].Reason = "changed"
+	again, _ := fs.Reports(ctx)
+	assert.Equal(t, "spam", again[0].Reason, "caller gets a copy")
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reports;
+ALTER TABLE shortener DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE shortener DROP COLUMN IF EXISTS status;
\ No newline at end of file
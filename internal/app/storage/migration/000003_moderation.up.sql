@@ -0,0 +1,9 @@
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS status varchar(32) NOT NULL DEFAULT '';
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS status_reason text NOT NULL DEFAULT '';
+create table if not exists reports(
+    id serial primary key,
+    short_url varchar(255) not null,
+    reason text,
+    reporter varchar(255),
+    remote_addr varchar(255),
+    created_at timestamptz not null default now());
\ No newline at end of file
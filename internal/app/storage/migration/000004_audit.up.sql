@@ -0,0 +1,23 @@
+create table if not exists audit_log(
+    id bigint primary key,
+    created_at timestamptz not null,
+    actor varchar(255) not null,
+    action varchar(32) not null,
+    code varchar(255) not null,
+    old_url text not null default '',
+    new_url text not null default '',
+    reason text not null default '',
+    remote_addr varchar(255) not null default '',
+    request_id varchar(255) not null default '',
+    prev_hash char(64) not null,
+    hash char(64) not null);
+create index if not exists audit_log_actor_idx on audit_log(actor);
+create index if not exists audit_log_code_idx on audit_log(code);
+create or replace function audit_log_append_only() returns trigger as $$
+begin
+    raise exception 'audit_log is append only';
+end;
+$$ language plpgsql;
+drop trigger if exists audit_log_append_only on audit_log;
+create trigger audit_log_append_only before update or delete on audit_log
+    for each row execute function audit_log_append_only();
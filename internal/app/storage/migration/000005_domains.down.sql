@@ -0,0 +1,6 @@
+ALTER TABLE audit_log DROP COLUMN IF EXISTS domain_id;
+ALTER TABLE reports DROP COLUMN IF EXISTS code;
+ALTER TABLE reports DROP COLUMN IF EXISTS domain_id;
+DROP INDEX IF EXISTS shortener_domain_code_idx;
+ALTER TABLE shortener DROP COLUMN IF EXISTS code;
+ALTER TABLE shortener DROP COLUMN IF EXISTS domain_id;
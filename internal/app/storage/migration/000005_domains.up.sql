@@ -0,0 +1,9 @@
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS domain_id varchar(64);
+ALTER TABLE shortener ADD COLUMN IF NOT EXISTS code varchar(255);
+ALTER TABLE shortener ALTER COLUMN short_url DROP NOT NULL;
+ALTER TABLE shortener DROP CONSTRAINT IF EXISTS shortener_short_url_key;
+create unique index if not exists shortener_domain_code_idx on shortener(domain_id, code);
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS domain_id varchar(64);
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS code varchar(255);
+ALTER TABLE reports ALTER COLUMN short_url DROP NOT NULL;
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS domain_id varchar(64) not null default '';
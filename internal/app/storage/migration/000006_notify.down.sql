@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS shortener_notify_change ON shortener;
+DROP FUNCTION IF EXISTS shortener_notify_change();
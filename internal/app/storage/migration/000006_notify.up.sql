@@ -0,0 +1,16 @@
+create or replace function shortener_notify_change() returns trigger as $$
+declare
+    r shortener%rowtype;
+begin
+    if tg_op = 'DELETE' then
+        r := old;
+    else
+        r := new;
+    end if;
+    perform pg_notify('shortener_links', coalesce(r.domain_id, '') || '/' || coalesce(r.code, ''));
+    return null;
+end;
+$$ language plpgsql;
+drop trigger if exists shortener_notify_change on shortener;
+create trigger shortener_notify_change after insert or update or delete on shortener
+    for each row execute function shortener_notify_change();
@@ -0,0 +1 @@
+ALTER TABLE shortener ALTER COLUMN original_url TYPE text;
@@ -0,0 +1,2 @@
+ALTER TABLE audit_log DROP COLUMN IF EXISTS new_state;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS old_state;
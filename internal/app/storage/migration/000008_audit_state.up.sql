@@ -0,0 +1,2 @@
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS old_state varchar(32) not null default '';
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS new_state varchar(32) not null default '';
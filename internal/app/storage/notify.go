@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/logger"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// changeChannel - channel notified by trigger on shortener with "domain/code" of changed link
+const changeChannel = "shortener_links"
+
+const (
+	minBackoff = 500 * time.Millisecond
+	maxBackoff = 30 * time.Second
+)
+
+// notifyConn - connection receiving notifications
+type notifyConn interface {
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// ChangeSubscriber - local state following link changes made by other instances
+type ChangeSubscriber interface {
+	// Changed - link was inserted, updated or deleted
+	Changed(key model.Key)
+	// Listening - changes are delivered from now on, or not delivered until next call
+	Listening(ok bool)
+}
+
+// cacheSubscriber - cache invalidated by changes, capped to fallbackTTL while changes are not delivered
+type cacheSubscriber struct {
+	cache       *CachedT
+	fallbackTTL time.Duration
+}
+
+// CacheSubscriber - cache following changes of other instances
+func CacheSubscriber(cache *CachedT, fallbackTTL time.Duration) ChangeSubscriber {
+	return cacheSubscriber{cache: cache, fallbackTTL: fallbackTTL}
+}
+
+// Changed - method
+func (c cacheSubscriber) Changed(key model.Key) {
+	c.cache.Invalidate(key)
+}
+
+// Listening - entries cached while changes were not delivered may be stale
+func (c cacheSubscriber) Listening(ok bool) {
+	if ok {
+		c.cache.Purge()
+		c.cache.SetMaxTTL(0)
+		return
+	}
+	c.cache.SetMaxTTL(c.fallbackTTL)
+	c.cache.Purge()
+}
+
+// ChangeListenerT - delivers notifications of links changed by instances sharing the database to subscribers
+type ChangeListenerT struct {
+	subs    []ChangeSubscriber
+	logger  logger.Logger
+	connect func(ctx context.Context) (notifyConn, error)
+	backoff time.Duration
+}
+
+// NewChangeListener - constructor, dsn is the database of the db backend
+func NewChangeListener(l logger.Logger, dsn string, subs ...ChangeSubscriber) *ChangeListenerT {
+	return &ChangeListenerT{
+		subs:   subs,
+		logger: l,
+		connect: func(ctx context.Context) (notifyConn, error) {
+			conn, err := pgx.Connect(ctx, dsn)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = conn.Exec(ctx, "LISTEN "+changeChannel); err != nil {
+				conn.Close(ctx)
+				return nil, err
+			}
+			return conn, nil
+		},
+		backoff: minBackoff,
+	}
+}
+
+func (n *ChangeListenerT) listening(ok bool) {
+	for _, s := range n.subs {
+		s.Listening(ok)
+	}
+}
+
+// Run - listens until ctx is done, reconnecting with exponential backoff
+func (n *ChangeListenerT) Run(ctx context.Context) {
+	n.listening(false)
+	for {
+		err := n.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// notifications are lost until reconnect
+		n.listening(false)
+		n.logger.WithFields(map[string]interface{}{
+			"error": err,
+			"retry": n.backoff.String(),
+		}).Warn("Change notification channel is down")
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(n.backoff):
+		}
+		n.backoff = min(2*n.backoff, maxBackoff)
+	}
+}
+
+// listen - delivers received notifications to subscribers until connection fails
+func (n *ChangeListenerT) listen(ctx context.Context) error {
+	conn, err := n.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+	n.listening(true)
+	n.backoff = minBackoff
+	n.logger.Info("Listening for link changes")
+	for {
+		msg, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if domain, code, ok := strings.Cut(msg.Payload, "/"); ok {
+			for _, s := range n.subs {
+				s.Changed(model.Key{Domain: domain, Code: code})
+			}
+		}
+	}
+}
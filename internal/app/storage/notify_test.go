@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConn - connection delivering notifications from channel, closed channel breaks connection
+type fakeConn struct {
+	ch chan string
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case p, ok := <-c.ch:
+		if !ok {
+			return nil, errors.New("connection reset")
+		}
+		return &pgconn.Notification{Channel: changeChannel, Payload: p}, nil
+	}
+}
+
+func (c *fakeConn) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestChangeListener(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	backend := newCounting(t)
+	cache := NewCached(backend, 10, time.Hour, time.Hour)
+	k := model.Key{Domain: "d", Code: "a"}
+	backend.StorageI.Store(ctx, k, "https://go.dev/")
+
+	conns := make(chan *fakeConn)
+	n := NewChangeListener(logger.NewDummy(), "", CacheSubscriber(cache, time.Hour))
+	n.connect = func(ctx context.Context) (notifyConn, error) {
+		select {
+		case c := <-conns:
+			return c, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	n.backoff = time.Millisecond
+	go n.Run(ctx)
+
+	maxTTL := func() time.Duration {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		return cache.maxTTL
+	}
+	require.Eventually(t, func() bool { return maxTTL() == time.Hour }, time.Second, time.Millisecond, "fallback until connected")
+	conn := &fakeConn{ch: make(chan string)}
+	conns <- conn
+	require.Eventually(t, func() bool { return maxTTL() == 0 }, time.Second, time.Millisecond, "no cap while listening")
+
+	cache.Load(ctx, k)
+	cache.Load(ctx, k)
+	assert.Equal(t, 1, backend.loads)
+	conn.ch <- "malformed"
+	conn.ch <- "d/a"
+	// notification after d/a is delivered only when d/a was handled
+	conn.ch <- "d/other"
+	cache.Load(ctx, k)
+	assert.Equal(t, 2, backend.loads, "invalidated by notification")
+
+	close(conn.ch)
+	require.Eventually(t, func() bool { return maxTTL() == time.Hour }, time.Second, time.Millisecond, "fallback while down")
+	conns <- &fakeConn{ch: make(chan string)}
+	require.Eventually(t, func() bool { return maxTTL() == 0 }, time.Second, time.Millisecond, "reconnected")
+}
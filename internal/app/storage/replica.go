@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/Stas9132/shortener/internal/metrics"
+)
+
+// lagQuery - seconds replica is behind primary, zero when all received changes are replayed or database is not a replica
+const lagQuery = `SELECT COALESCE(CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END, 0)`
+
+// replicaT - read replica of primary database
+type replicaT struct {
+	// name - position in config, dsn may hold password
+	name    string
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// readersT - routes reads to healthy replicas, reads of users who just created links go to primary
+type readersT struct {
+	logger       logger.Logger
+	replicas     []*replicaT
+	next         atomic.Uint64
+	checkTimeout time.Duration
+	maxLag       time.Duration
+	pinFor       time.Duration
+
+	mu sync.Mutex
+	// pinned - users reading from primary until time
+	pinned map[string]time.Time
+}
+
+type userKey struct{}
+
+type primaryKey struct{}
+
+// WithUser - context of request made by user, reads of user pinned after creating link go to primary
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// withPrimary - context of reads which must see latest writes
+func withPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// newReaders - opens replicas of cfg, they serve reads after first health check
+func newReaders(l logger.Logger, cfg config.ReplicaConfig) (*readersT, error) {
+	r := &readersT{
+		logger:       l,
+		checkTimeout: time.Duration(cfg.CheckTimeout),
+		maxLag:       time.Duration(cfg.MaxLag),
+		pinFor:       time.Duration(cfg.ReadYourWrites),
+		pinned:       make(map[string]time.Time),
+	}
+	for i, dsn := range cfg.DSNs {
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		r.replicas = append(r.replicas, &replicaT{name: strconv.Itoa(i), db: db})
+	}
+	return r, nil
+}
+
+// pin - user reads from primary for pinFor
+func (r *readersT) pin(user string) {
+	if r == nil || r.pinFor <= 0 || len(r.replicas) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pinned[user] = time.Now().Add(r.pinFor)
+}
+
+// isPinned - user created link recently
+func (r *readersT) isPinned(ctx context.Context) bool {
+	user, ok := ctx.Value(userKey{}).(string)
+	if !ok {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	until, ok := r.pinned[user]
+	return ok && time.Now().Before(until)
+}
+
+// unpin - drops expired pins
+func (r *readersT) unpin() {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for user, until := range r.pinned {
+		if !now.Before(until) {
+			delete(r.pinned, user)
+		}
+	}
+}
+
+// pick - replica serving read in ctx and target for metrics, nil replica means primary
+func (r *readersT) pick(ctx context.Context) (*replicaT, string) {
+	if r == nil || len(r.replicas) == 0 {
+		return nil, "primary"
+	}
+	if ctx.Value(primaryKey{}) != nil {
+		return nil, "primary"
+	}
+	if r.isPinned(ctx) {
+		return nil, "pinned"
+	}
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; rep.healthy.Load() {
+			return rep, "replica"
+		}
+	}
+	return nil, "fallback"
+}
+
+// check - replica answers and is not lagging more than maxLag
+func (r *readersT) check(ctx context.Context, rep *replicaT) error {
+	ctx, cancel := context.WithTimeout(ctx, r.checkTimeout)
+	defer cancel()
+	if err := rep.db.PingContext(ctx); err != nil {
+		return err
+	}
+	if r.maxLag <= 0 {
+		return nil
+	}
+	var lag float64
+	if err := rep.db.QueryRowContext(ctx, lagQuery).Scan(&lag); err != nil {
+		return err
+	}
+	if lag > r.maxLag.Seconds() {
+		return fmt.Errorf("replication lag %.1fs exceeds %s", lag, r.maxLag)
+	}
+	return nil
+}
+
+// setHealthy - records result of check or failed read, logging changes
+func (r *readersT) setHealthy(rep *replicaT, err error) {
+	up := err == nil
+	metrics.SetReplicaUp(rep.name, up)
+	if rep.healthy.Swap(up) == up {
+		return
+	}
+	if up {
+		r.logger.WithField("replica", rep.name).Info("Database replica is up")
+	} else {
+		r.logger.WithFields(map[string]interface{}{
+			"replica": rep.name,
+			"error":   err,
+		}).Warn("Database replica is down, reads go to other replicas or primary")
+	}
+}
+
+// checkAll - checks replicas concurrently
+func (r *readersT) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func(rep *replicaT) {
+			defer wg.Done()
+			r.setHealthy(rep, r.check(ctx, rep))
+		}(rep)
+	}
+	wg.Wait()
+}
+
+// Run - checks replicas and drops expired pins every interval until ctx is done
+func (r *readersT) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		r.checkAll(ctx)
+		r.unpin()
+	}
+}
+
+// Close - closes replicas
+func (r *readersT) Close() error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for _, rep := range r.replicas {
+		errs = append(errs, rep.db.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaders_Pick(t *testing.T) {
+	ctx := context.Background()
+	var none *readersT
+	rep, target := none.pick(ctx)
+	assert.Nil(t, rep)
+	assert.Equal(t, "primary", target, "no replicas")
+
+	r, err := newReaders(logger.NewDummy(), config.ReplicaConfig{
+		DSNs:           []string{"postgres://replica0/app", "postgres://replica1/app"},
+		ReadYourWrites: config.Duration(time.Hour),
+	})
+	require.NoError(t, err)
+	defer r.Close()
+
+	_, target = r.pick(ctx)
+	assert.Equal(t, "fallback", target, "replicas serve reads after first check")
+
+	r.setHealthy(r.replicas[0], nil)
+	r.setHealthy(r.replicas[1], nil)
+	seen := map[*replicaT]bool{}
+	for i := 0; i < 4; i++ {
+		rep, target = r.pick(ctx)
+		assert.Equal(t, "replica", target)
+		seen[rep] = true
+	}
+	assert.Len(t, seen, 2, "reads spread over replicas")
+
+	r.setHealthy(r.replicas[0], errors.New("connection refused"))
+	for i := 0; i < 4; i++ {
+		rep, _ = r.pick(ctx)
+		assert.Same(t, r.replicas[1], rep, "down replica skipped")
+	}
+
+	rep, target = r.pick(withPrimary(ctx))
+	assert.Nil(t, rep)
+	assert.Equal(t, "primary", target)
+
+	user := WithUser(ctx, "u1")
+	r.pin("u1")
+	rep, target = r.pick(user)
+	assert.Nil(t, rep)
+	assert.Equal(t, "pinned", target, "read your writes")
+	_, target = r.pick(WithUser(ctx, "u2"))
+	assert.Equal(t, "replica", target, "other users read from replicas")
+
+	r.pinned["u1"] = time.Now().Add(-time.Second)
+	_, target = r.pick(user)
+	assert.Equal(t, "replica", target, "pin expired")
+	r.unpin()
+	assert.Empty(t, r.pinned)
+}
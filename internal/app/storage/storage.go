@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrNotFound - record not found
+var ErrNotFound = errors.New("not found")
+
+// StorageI - interface to storage
+type StorageI interface {
+	Load(ctx context.Context, key model.Key) (value string, ok bool)
+	Store(ctx context.Context, key model.Key, value string)
+	RangeExt(ctx context.Context, f func(key model.Key, value, user string) bool)
+	Range(ctx context.Context, f func(key model.Key, value string) bool)
+	LoadOrStore(ctx context.Context, key model.Key, value string) (actual string, loaded bool)
+	LoadOrStoreExt(ctx context.Context, key model.Key, value, user string) (actual string, loaded bool)
+	Delete(ctx context.Context, keys ...model.Key)
+	Ping(ctx context.Context) error
+	Close() error
+	SetStatus(ctx context.Context, key model.Key, status model.LinkStatus) error
+	Status(ctx context.Context, key model.Key) model.LinkStatus
+	AddReport(ctx context.Context, report model.Report) error
+	Reports(ctx context.Context) ([]model.Report, error)
+	AddAuditEvent(ctx context.Context, event model.AuditEvent) error
+	AuditEvents(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error)
+}
+
+// legacyKey converts full short url stored before domains existed to key.
+// Domain is the one whose base url prefixes shortURL, otherwise the default one.
+func legacyKey(domains []config.DomainConfig, shortURL string) model.Key {
+	for _, d := range domains {
+		base := strings.TrimSuffix(d.BaseURL, "/") + "/"
+		if code, ok := strings.CutPrefix(shortURL, base); ok && code != "" && !strings.Contains(code, "/") {
+			return model.Key{Domain: d.ID, Code: code}
+		}
+	}
+	code := shortURL
+	if u, err := url.Parse(shortURL); err == nil {
+		code = u.Path
+	}
+	return model.Key{Domain: domains[0].ID, Code: path.Base(code)}
+}
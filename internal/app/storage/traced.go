@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"github.com/Stas9132/shortener/internal/app/model"
+	"github.com/Stas9132/shortener/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TracedT - storage decorator creating span around each call
+type TracedT struct {
+	StorageI
+	backend string
+}
+
+// NewTraced - constructor, backend is recorded as span attribute
+func NewTraced(st StorageI, backend string) *TracedT {
+	return &TracedT{StorageI: st, backend: backend}
+}
+
+func (s *TracedT) start(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	ctx, span := tracing.Start(ctx, "storage."+op, append(attrs, attribute.String("storage.backend", s.backend))...)
+	return ctx, func(err error) { tracing.End(span, err) }
+}
+
+// Load - method
+func (s *TracedT) Load(ctx context.Context, key model.Key) (string, bool) {
+	ctx, end := s.start(ctx, "Load", attribute.String("key", key.String()))
+	defer end(nil)
+	return s.StorageI.Load(ctx, key)
+}
+
+// Store - method
+func (s *TracedT) Store(ctx context.Context, key model.Key, value string) {
+	ctx, end := s.start(ctx, "Store", attribute.String("key", key.String()))
+	defer end(nil)
+	s.StorageI.Store(ctx, key, value)
+}
+
+// RangeExt - method
+func (s *TracedT) RangeExt(ctx context.Context, f func(key model.Key, value, user string) bool) {
+	ctx, end := s.start(ctx, "RangeExt")
+	defer end(nil)
+	s.StorageI.RangeExt(ctx, f)
+}
+
+// Range - method
+func (s *TracedT) Range(ctx context.Context, f func(key model.Key, value string) bool) {
+	ctx, end := s.start(ctx, "Range")
+	defer end(nil)
+	s.StorageI.Range(ctx, f)
+}
+
+// LoadOrStore - method
+func (s *TracedT) LoadOrStore(ctx context.Context, key model.Key, value string) (string, bool) {
+	ctx, end := s.start(ctx, "LoadOrStore", attribute.String("key", key.String()))
+	defer end(nil)
+	return s.StorageI.LoadOrStore(ctx, key, value)
+}
+
+// LoadOrStoreExt - method
+func (s *TracedT) LoadOrStoreExt(ctx context.Context, key model.Key, value, user string) (string, bool) {
+	ctx, end := s.start(ctx, "LoadOrStoreExt", attribute.String("key", key.String()))
+	defer end(nil)
+	return s.StorageI.LoadOrStoreExt(ctx, key, value, user)
+}
+
+// Delete - method
+func (s *TracedT) Delete(ctx context.Context, keys ...model.Key) {
+	ctx, end := s.start(ctx, "Delete", attribute.Int("keys", len(keys)))
+	defer end(nil)
+	s.StorageI.Delete(ctx, keys...)
+}
+
+// Ping - method
+func (s *TracedT) Ping(ctx context.Context) (err error) {
+	ctx, end := s.start(ctx, "Ping")
+	defer func() { end(err) }()
+	return s.StorageI.Ping(ctx)
+}
+
+// SetStatus - method
+func (s *TracedT) SetStatus(ctx context.Context, key model.Key, status model.LinkStatus) (err error) {
+	ctx, end := s.start(ctx, "SetStatus", attribute.String("key", key.String()))
+	defer func() { end(err) }()
+	return s.StorageI.SetStatus(ctx, key, status)
+}
+
+// Status - method
+func (s *TracedT) Status(ctx context.Context, key model.Key) model.LinkStatus {
+	ctx, end := s.start(ctx, "Status", attribute.String("key", key.String()))
+	defer end(nil)
+	return s.StorageI.Status(ctx, key)
+}
+
+// AddReport - method
+func (s *TracedT) AddReport(ctx context.Context, report model.Report) (err error) {
+	ctx, end := s.start(ctx, "AddReport", attribute.String("key", report.Key.String()))
+	defer func() { end(err) }()
+	return s.StorageI.AddReport(ctx, report)
+}
+
+// Reports - method
+func (s *TracedT) Reports(ctx context.Context) (_ []model.Report, err error) {
+	ctx, end := s.start(ctx, "Reports")
+	defer func() { end(err) }()
+	return s.StorageI.Reports(ctx)
+}
+
+// AddAuditEvent - method
+func (s *TracedT) AddAuditEvent(ctx context.Context, event model.AuditEvent) (err error) {
+	ctx, end := s.start(ctx, "AddAuditEvent", attribute.String("action", event.Action))
+	defer func() { end(err) }()
+	return s.StorageI.AddAuditEvent(ctx, event)
+}
+
+// AuditEvents - method
+func (s *TracedT) AuditEvents(ctx context.Context, filter model.AuditFilter) (_ []model.AuditEvent, err error) {
+	ctx, end := s.start(ctx, "AuditEvents")
+	defer func() { end(err) }()
+	return s.StorageI.AuditEvents(ctx, filter)
+}
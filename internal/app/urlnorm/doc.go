@@ -0,0 +1,2 @@
+// Package urlnorm - validation and normalization of destination URLs
+package urlnorm
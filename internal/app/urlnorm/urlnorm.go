@@ -0,0 +1,179 @@
+package urlnorm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/idna"
+)
+
+// Validation errors
+var (
+	ErrEmpty          = errors.New("url is empty")
+	ErrTooLong        = errors.New("url is too long")
+	ErrInvalid        = errors.New("url is invalid")
+	ErrScheme         = errors.New("url scheme is not allowed")
+	ErrNoHost         = errors.New("url host is required")
+	ErrPrivateAddress = errors.New("url points to private or loopback address")
+	ErrUnresolved     = errors.New("url host cannot be resolved")
+)
+
+// DefaultMaxLength - max url length used when Options.MaxLength is zero
+const DefaultMaxLength = 2048
+
+// DefaultLookupTimeout - limit of host resolution used when Options.LookupTimeout is zero
+const DefaultLookupTimeout = 2 * time.Second
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// Options - normalizer settings
+type Options struct {
+	AllowedSchemes     []string
+	MaxLength          int
+	StripTrailingSlash bool
+	SortQuery          bool
+	// BlockPrivate rejects hosts resolving to private or loopback addresses and hosts which cannot be resolved,
+	// so destination failing resolution now cannot point inside later
+	BlockPrivate bool
+	// LookupIP resolves host names when BlockPrivate is set, net.DefaultResolver is used when nil
+	LookupIP func(ctx context.Context, host string) ([]net.IP, error)
+	// LookupTimeout - limit of each resolution
+	LookupTimeout time.Duration
+}
+
+// Normalizer struct
+type Normalizer struct {
+	schemes map[string]struct{}
+	opts    Options
+}
+
+// New - constructor
+func New(opts Options) *Normalizer {
+	if len(opts.AllowedSchemes) == 0 {
+		opts.AllowedSchemes = []string{"http", "https"}
+	}
+	if opts.MaxLength <= 0 {
+		opts.MaxLength = DefaultMaxLength
+	}
+	if opts.LookupIP == nil {
+		opts.LookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+			return net.DefaultResolver.LookupIP(ctx, "ip", host)
+		}
+	}
+	if opts.LookupTimeout <= 0 {
+		opts.LookupTimeout = DefaultLookupTimeout
+	}
+	s := make(map[string]struct{}, len(opts.AllowedSchemes))
+	for _, v := range opts.AllowedSchemes {
+		s[strings.ToLower(v)] = struct{}{}
+	}
+	return &Normalizer{schemes: s, opts: opts}
+}
+
+// Normalize - validates raw url and returns it in canonical form, host resolution is bound to ctx
+func (n *Normalizer) Normalize(ctx context.Context, raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ErrEmpty
+	}
+	if len(raw) > n.opts.MaxLength {
+		return "", ErrTooLong
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if _, ok := n.schemes[u.Scheme]; !ok {
+		return "", ErrScheme
+	}
+	if u.Opaque != "" {
+		return "", ErrInvalid
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return "", ErrNoHost
+	}
+	port := u.Port()
+	if ip := net.ParseIP(host); ip == nil {
+		host, err = idna.Lookup.ToASCII(strings.TrimSuffix(host, "."))
+		if err != nil || host == "" {
+			return "", ErrInvalid
+		}
+	}
+	host = strings.ToLower(host)
+	if n.opts.BlockPrivate {
+		if err = n.checkAddress(ctx, host); err != nil {
+			return "", err
+		}
+	}
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if n.opts.StripTrailingSlash && u.Path != "/" {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = strings.TrimSuffix(u.RawPath, "/")
+	}
+	if n.opts.SortQuery && u.RawQuery != "" {
+		u.RawQuery = sortQuery(u.RawQuery)
+	}
+
+	res := u.String()
+	if len(res) > n.opts.MaxLength {
+		return "", ErrTooLong
+	}
+	return res, nil
+}
+
+// checkAddress - ErrPrivateAddress when host is or resolves to internal address, ErrUnresolved when resolution fails
+func (n *Normalizer) checkAddress(ctx context.Context, host string) error {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		ctx, cancel := context.WithTimeout(ctx, n.opts.LookupTimeout)
+		defer cancel()
+		var err error
+		if ips, err = n.opts.LookupIP(ctx, host); err != nil || len(ips) == 0 {
+			return ErrUnresolved
+		}
+	}
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+			ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// sortQuery orders parameters by key keeping the relative order of repeated keys
+func sortQuery(q string) string {
+	parts := strings.Split(q, "&")
+	sort.SliceStable(parts, func(i, j int) bool {
+		ki, _, _ := strings.Cut(parts[i], "=")
+		kj, _, _ := strings.Cut(parts[j], "=")
+		return ki < kj
+	})
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,70 @@
+package urlnorm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizer_Normalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{name: "Unchanged", raw: "https://go.dev/", want: "https://go.dev/"},
+		{name: "Whitespace trimmed", raw: "  https://go.dev/\n", want: "https://go.dev/"},
+		{name: "Empty", raw: " \t", wantErr: ErrEmpty},
+		{name: "Javascript scheme", raw: "javascript:alert(1)", wantErr: ErrScheme},
+		{name: "Relative", raw: "/path", wantErr: ErrScheme},
+		{name: "No host", raw: "http:///path", wantErr: ErrNoHost},
+		{name: "Lowercase scheme and host", raw: "HTTP://Go.DEV/Doc", want: "http://go.dev/Doc"},
+		{name: "Default port stripped", raw: "https://go.dev:443/doc", want: "https://go.dev/doc"},
+		{name: "Custom port kept", raw: "https://go.dev:8443/doc", want: "https://go.dev:8443/doc"},
+		{name: "IDN to punycode", raw: "http://пример.рф/", want: "http://xn--e1afmkfd.xn--p1ai/"},
+		{name: "IPv6 default port", raw: "http://[::1]:80/", want: "http://[::1]/"},
+		{name: "Too long", opts: Options{MaxLength: 20}, raw: "https://go.dev/" + strings.Repeat("a", 10), wantErr: ErrTooLong},
+		{name: "Custom scheme allowed", opts: Options{AllowedSchemes: []string{"ftp"}}, raw: "ftp://example.com:21/f", want: "ftp://example.com/f"},
+		{name: "Trailing slash kept", raw: "https://go.dev/doc/", want: "https://go.dev/doc/"},
+		{name: "Trailing slash stripped", opts: Options{StripTrailingSlash: true}, raw: "https://go.dev/doc/", want: "https://go.dev/doc"},
+		{name: "Root slash kept", opts: Options{StripTrailingSlash: true}, raw: "https://go.dev/", want: "https://go.dev/"},
+		{name: "Query sorted", opts: Options{SortQuery: true}, raw: "https://go.dev/?b=2&a=1&b=1", want: "https://go.dev/?a=1&b=2&b=1"},
+		{name: "Loopback allowed", raw: "http://127.0.0.1/", want: "http://127.0.0.1/"},
+		{name: "Loopback blocked", opts: Options{BlockPrivate: true}, raw: "http://127.0.0.1/", wantErr: ErrPrivateAddress},
+		{name: "Localhost blocked", opts: Options{BlockPrivate: true}, raw: "http://localhost:8080/", wantErr: ErrPrivateAddress},
+		{name: "Private resolved blocked", opts: Options{BlockPrivate: true, LookupIP: func(context.Context, string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		}}, raw: "http://intranet.example/", wantErr: ErrPrivateAddress},
+		{name: "Public resolved allowed", opts: Options{BlockPrivate: true, LookupIP: func(context.Context, string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("8.8.8.8")}, nil
+		}}, raw: "http://example.com/", want: "http://example.com/"},
+		{name: "Unresolved rejected", opts: Options{BlockPrivate: true, LookupIP: func(context.Context, string) ([]net.IP, error) {
+			return nil, errors.New("no such host")
+		}}, raw: "http://example.com/", wantErr: ErrUnresolved},
+		{name: "Slow lookup times out", opts: Options{BlockPrivate: true, LookupTimeout: time.Millisecond, LookupIP: func(ctx context.Context, _ string) ([]net.IP, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}}, raw: "http://example.com/", wantErr: ErrUnresolved},
+		{name: "Unresolved allowed without blocking", opts: Options{LookupIP: func(context.Context, string) ([]net.IP, error) {
+			return nil, errors.New("no such host")
+		}}, raw: "http://example.com/", want: "http://example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.opts).Normalize(context.Background(), tt.raw)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
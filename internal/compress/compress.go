@@ -0,0 +1,171 @@
+package compress
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/Stas9132/shortener/config"
+)
+
+// compressible - media types worth compressing besides text/*
+var compressible = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"image/svg+xml":          true,
+}
+
+// Compressible - reports whether response of content type benefits from compression
+func Compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mt, "text/") || compressible[mt] ||
+		strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml")
+}
+
+// compressWriter реализует интерфейс http.ResponseWriter и позволяет прозрачно для сервера
+// сжимать передаваемые данные и выставлять правильные HTTP-заголовки.
+// Первые minSize байт буферизуются, чтобы короткие ответы уходили без сжатия.
+type compressWriter struct {
+	w       http.ResponseWriter
+	coding  string
+	minSize int
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func newCompressWriter(w http.ResponseWriter, coding string, minSize int) *compressWriter {
+	return &compressWriter{w: w, coding: coding, minSize: minSize}
+}
+
+// Header - method
+func (c *compressWriter) Header() http.Header {
+	return c.w.Header()
+}
+
+// WriteHeader - method, header is sent once compression is decided
+func (c *compressWriter) WriteHeader(statusCode int) {
+	if c.status != 0 || c.decided {
+		return
+	}
+	if statusCode < 200 {
+		c.w.WriteHeader(statusCode)
+		return
+	}
+	c.status = statusCode
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		c.decide(false)
+	}
+}
+
+// Write - method
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.decided {
+		if c.enc != nil {
+			return c.enc.Write(p)
+		}
+		return c.w.Write(p)
+	}
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= c.minSize {
+		if err := c.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide sends header, with encoding when allowed and content type is compressible, then buffered data
+func (c *compressWriter) decide(allowed bool) error {
+	c.decided = true
+	h := c.w.Header()
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if h.Get("Content-Type") == "" && len(c.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+	if allowed && h.Get("Content-Encoding") == "" && Compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", c.coding)
+		h.Del("Content-Length")
+		c.enc = getEncoder(c.coding, c.w)
+	}
+	c.w.WriteHeader(c.status)
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if c.enc != nil {
+		_, err = c.enc.Write(buf)
+	} else {
+		_, err = c.w.Write(buf)
+	}
+	return err
+}
+
+// Flush - method, sends what is written so far compressing it when large enough
+func (c *compressWriter) Flush() {
+	if !c.decided {
+		_ = c.decide(len(c.buf) >= c.minSize)
+	}
+	if c.enc != nil {
+		_ = c.enc.Flush()
+	}
+	_ = http.NewResponseController(c.w).Flush()
+}
+
+// Unwrap - underlying writer for http.ResponseController
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.w
+}
+
+// Close завершает ответ: отправляет буфер и возвращает кодировщик в пул.
+func (c *compressWriter) Close() error {
+	if !c.decided {
+		if c.status == 0 && len(c.buf) == 0 {
+			return nil
+		}
+		if err := c.decide(false); err != nil {
+			return err
+		}
+	}
+	if c.enc == nil {
+		return nil
+	}
+	err := c.enc.Close()
+	putEncoder(c.coding, c.enc)
+	c.enc = nil
+	return err
+}
+
+// New - middleware compressing responses with coding negotiated from Accept-Encoding
+// and decoding request bodies within configured limits, see decodeRequest
+func New(cfg config.CompressConfig) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(cfg.Encodings) > 0 {
+				w.Header().Add("Vary", "Accept-Encoding")
+			}
+			if coding := Negotiate(r.Header.Get("Accept-Encoding"), cfg.Encodings); coding != "" && r.Method != http.MethodHead {
+				cw := newCompressWriter(w, coding, int(cfg.MinSize))
+				w = cw
+				defer cw.Close()
+			}
+			if err := decodeRequest(w, r, int64(cfg.MaxRequestSize), int64(cfg.MaxDecodedSize)); err != nil {
+				http.Error(w, err.msg, err.status)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockResponseWriter struct {
+	callCheck       map[string]struct{}
+	retHeader       http.Header
+	passWrite       []byte
+	passWriteHeader int
+}
+
+func (w *mockResponseWriter) Header() http.Header {
+	w.callCheck["Header"] = struct{}{}
+	return w.retHeader
+}
+
+func (w *mockResponseWriter) Write(b []byte) (int, error) {
+	w.callCheck["Write"] = struct{}{}
+	w.passWrite = append(w.passWrite, b...)
+	return len(b), nil
+}
+
+func (w *mockResponseWriter) WriteHeader(statusCode int) {
+	w.passWriteHeader = statusCode
+	w.callCheck["WriteHeader"] = struct{}{}
+}
+
+func TestCompressWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		encoding string
+	}{
+		{name: "Short body sent as is", status: http.StatusOK, body: `{"a":1}`},
+		{name: "Long json compressed", status: http.StatusOK, body: `{"a":"` + strings.Repeat("x", 64) + `"}`, encoding: "gzip"},
+		{name: "Error status compressed too", status: http.StatusBadRequest, body: strings.Repeat("bad request\n", 8), encoding: "gzip"},
+		{name: "No content", status: http.StatusNoContent},
+		{name: "Binary not compressed", status: http.StatusOK, body: "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 64)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tw := &mockResponseWriter{callCheck: make(map[string]struct{}), retHeader: http.Header{}}
+			cw := newCompressWriter(tw, "gzip", 32)
+			if strings.HasPrefix(tt.body, "{") {
+				cw.Header().Set("Content-Type", "application/json")
+			}
+			cw.WriteHeader(tt.status)
+			_, ok := tw.callCheck["WriteHeader"]
+			require.Equal(t, tt.status == http.StatusNoContent, ok, "header is delayed until compression is decided")
+			_, err := cw.Write([]byte(tt.body))
+			require.NoError(t, err)
+			require.NoError(t, cw.Close())
+
+			assert.Equal(t, tt.status, tw.passWriteHeader)
+			assert.Equal(t, tt.encoding, tw.retHeader.Get("Content-Encoding"))
+			got := tw.passWrite
+			if tt.encoding != "" {
+				zr, err := gzip.NewReader(bytes.NewReader(got))
+				require.NoError(t, err)
+				got, err = io.ReadAll(zr)
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.body, string(got))
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	supported := []string{"br", "zstd", "gzip", "deflate"}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "gzip", want: "gzip"},
+		{header: "GZIP, Deflate", want: "gzip"},
+		{header: "gzip, deflate, br, zstd", want: "br"},
+		{header: "gzip;q=1.0, br;q=0.5", want: "gzip"},
+		{header: "br;q=0, gzip;q=0.1", want: "gzip"},
+		{header: "*", want: "br"},
+		{header: "*;q=0.5, br;q=0", want: "zstd"},
+		{header: "identity", want: ""},
+		{header: "grip", want: ""},
+		{header: "gzip;q=2", want: ""},
+		{header: "gzip; q=0.8, deflate; q=0.9", want: "deflate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			assert.Equal(t, tt.want, Negotiate(tt.header, supported))
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	body := strings.Repeat("<p>hello</p>\n", 200)
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(b)
+	})
+	h := New(config.Default().Compress)(echo)
+	for _, coding := range config.Default().Compress.Encodings {
+		t.Run(coding, func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+				r.Header.Set("Accept-Encoding", coding)
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				require.Equal(t, coding, w.Header().Get("Content-Encoding"))
+				assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+				assert.Less(t, w.Body.Len(), len(body))
+				zr, err := decoders[coding](w.Body, 0)
+				require.NoError(t, err)
+				got, err := io.ReadAll(zr)
+				require.NoError(t, err)
+				assert.Equal(t, body, string(got), "pooled encoder reused cleanly")
+			}
+		})
+	}
+
+	t.Run("Gzip request body", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write([]byte("https://go.dev/"))
+		require.NoError(t, zw.Close())
+		r := httptest.NewRequest(http.MethodPost, "/", &buf)
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, "https://go.dev/", w.Body.String())
+	})
+
+	t.Run("Broken gzip request body", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("plain"))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		New(config.CompressConfig{})(echo).ServeHTTP(w, r)
+		assert.Equal(t, body, w.Body.String())
+	})
+}
+
+func BenchmarkNew(b *testing.B) {
+	body := []byte(strings.Repeat(`{"short_url":"http://localhost:8080/80886a13"},`, 100))
+	h := New(config.Default().Compress)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	}))
+	for _, coding := range config.Default().Compress.Encodings {
+		b.Run(coding, func(b *testing.B) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", coding)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), r)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// httpError - request rejected with status
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+// errTooLarge - body exceeds one of the limits
+var errTooLarge = &httpError{status: http.StatusRequestEntityTooLarge, msg: "request body too large"}
+
+// decoders - request body decoders by content coding, maxSize bounds decoder memory where supported
+var decoders = map[string]func(r io.Reader, maxSize int64) (io.ReadCloser, error){
+	"gzip": func(r io.Reader, _ int64) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	},
+	"deflate": func(r io.Reader, _ int64) (io.ReadCloser, error) {
+		return zlib.NewReader(r)
+	},
+	"br": func(r io.Reader, _ int64) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	},
+	"zstd": func(r io.Reader, maxSize int64) (io.ReadCloser, error) {
+		opts := []zstd.DOption{zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true)}
+		if maxSize > 0 {
+			opts = append(opts, zstd.WithDecoderMaxMemory(uint64(maxSize)))
+		}
+		d, err := zstd.NewReader(r, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	},
+}
+
+// decodeRequest limits request body to maxRequest bytes as sent and, when Content-Encoding is set,
+// replaces it with body decoded in full but not longer than maxDecoded bytes.
+// Codings listed in Content-Encoding are undone in reverse order. Zero limit disables the check.
+func decodeRequest(w http.ResponseWriter, r *http.Request, maxRequest, maxDecoded int64) *httpError {
+	if maxRequest > 0 {
+		if r.ContentLength > maxRequest {
+			return errTooLarge
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequest)
+	}
+	header := r.Header.Get("Content-Encoding")
+	if header == "" {
+		return nil
+	}
+	var codings []string
+	for _, c := range strings.Split(header, ",") {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" && c != "identity" {
+			codings = append(codings, c)
+		}
+	}
+	var body io.Reader = r.Body
+	for i := len(codings) - 1; i >= 0; i-- {
+		newDecoder, ok := decoders[codings[i]]
+		if !ok {
+			return &httpError{status: http.StatusUnsupportedMediaType, msg: "unsupported content encoding " + strconv.Quote(codings[i])}
+		}
+		d, err := newDecoder(body, maxDecoded)
+		if err != nil {
+			return decodeError(codings[i], err)
+		}
+		defer d.Close()
+		body = d
+	}
+	if maxDecoded > 0 {
+		body = io.LimitReader(body, maxDecoded+1)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return decodeError(header, err)
+	}
+	if maxDecoded > 0 && int64(len(b)) > maxDecoded {
+		return errTooLarge
+	}
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Del("Content-Encoding")
+	r.Header.Set("Content-Length", strconv.Itoa(len(b)))
+	return nil
+}
+
+// decodeError maps decoder failure to 413 when body or decoder memory limit is hit, 400 otherwise
+func decodeError(coding string, err error) *httpError {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) || errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return errTooLarge
+	}
+	return &httpError{status: http.StatusBadRequest, msg: fmt.Sprintf("malformed %s body: %v", coding, err)}
+}
@@ -0,0 +1,95 @@
+package compress
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encode applies codings in order given, as listed in Content-Encoding
+func encode(t *testing.T, body []byte, codings ...string) []byte {
+	for _, c := range codings {
+		var buf bytes.Buffer
+		e := getEncoder(c, &buf)
+		_, err := e.Write(body)
+		require.NoError(t, err)
+		require.NoError(t, e.Close())
+		putEncoder(c, e)
+		body = buf.Bytes()
+	}
+	return body
+}
+
+func TestDecodeRequest(t *testing.T) {
+	plain := []byte(`[{"correlation_id":"1","original_url":"https://go.dev/"}]`)
+	bomb := encode(t, make([]byte, 10<<20), "gzip")
+	require.Less(t, len(bomb), 64<<10)
+	noise := make([]byte, 128<<10)
+	_, _ = rand.Read(noise)
+
+	tests := []struct {
+		name       string
+		encoding   string
+		body       []byte
+		chunked    bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "Plain", body: plain, wantStatus: http.StatusOK, wantBody: string(plain)},
+		{name: "Gzip", encoding: "gzip", body: encode(t, plain, "gzip"), wantStatus: http.StatusOK, wantBody: string(plain)},
+		{name: "Deflate", encoding: "deflate", body: encode(t, plain, "deflate"), wantStatus: http.StatusOK, wantBody: string(plain)},
+		{name: "Brotli", encoding: "br", body: encode(t, plain, "br"), wantStatus: http.StatusOK, wantBody: string(plain)},
+		{name: "Zstd", encoding: "ZSTD", body: encode(t, plain, "zstd"), wantStatus: http.StatusOK, wantBody: string(plain)},
+		{name: "Chained", encoding: "br, identity, gzip", body: encode(t, plain, "br", "gzip"), wantStatus: http.StatusOK, wantBody: string(plain)},
+		{name: "Malformed gzip", encoding: "gzip", body: plain, wantStatus: http.StatusBadRequest},
+		{name: "Truncated gzip", encoding: "gzip", body: encode(t, plain, "gzip")[:20], wantStatus: http.StatusBadRequest},
+		{name: "Malformed zstd", encoding: "zstd", body: plain, wantStatus: http.StatusBadRequest},
+		{name: "Unknown coding", encoding: "compress", body: plain, wantStatus: http.StatusUnsupportedMediaType},
+		{name: "Gzip bomb", encoding: "gzip", body: bomb, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "Zstd bomb", encoding: "zstd", body: encode(t, make([]byte, 10<<20), "zstd"), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "Too long", body: make([]byte, 128<<10), wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "Too long compressed chunked", encoding: "gzip", body: encode(t, noise, "gzip"), chunked: true,
+			wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "Too long chunked", body: make([]byte, 128<<10), chunked: true, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if assert.ErrorAs(t, err, &mbe) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+			}
+			return
+		}
+		assert.Empty(t, r.Header.Get("Content-Encoding"))
+		_, _ = w.Write(b)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = bytes.NewReader(tt.body)
+			if tt.chunked {
+				body = io.MultiReader(body)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", body)
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			if err := decodeRequest(w, r, 64<<10, 1<<20); err != nil {
+				w.WriteHeader(err.status)
+			} else {
+				h.ServeHTTP(w, r)
+			}
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package compress - http middleware negotiating response compression and decoding gzip request bodies
+package compress
@@ -0,0 +1,47 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoder - compressing writer which can be reused for another destination
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// pools - encoders by content coding, reset to io.Discard while idle
+var pools = map[string]*sync.Pool{
+	"gzip": {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+	// "deflate" content coding is zlib stream, RFC 9110 8.4.1.2
+	"deflate": {New: func() any {
+		return zlib.NewWriter(io.Discard)
+	}},
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(io.Discard, 4)
+	}},
+	"zstd": {New: func() any {
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(1<<20))
+		return w
+	}},
+}
+
+func getEncoder(coding string, w io.Writer) encoder {
+	e := pools[coding].Get().(encoder)
+	e.Reset(w)
+	return e
+}
+
+func putEncoder(coding string, e encoder) {
+	e.Reset(io.Discard)
+	pools[coding].Put(e)
+}
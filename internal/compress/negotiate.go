@@ -0,0 +1,52 @@
+package compress
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Negotiate - picks content coding for Accept-Encoding header value from supported ones listed in order of preference.
+// Highest q-value wins, ties go to the earlier supported coding, "*" covers codings not named in header.
+// Returns empty string when response should not be encoded.
+func Negotiate(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+	q := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		v := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, val, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(k)) != "q" {
+				continue
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+			if err != nil || f < 0 || f > 1 {
+				f = 0
+			}
+			v = f
+		}
+		if name == "*" {
+			wildcard = v
+			continue
+		}
+		q[name] = v
+	}
+	best, bestQ := "", 0.0
+	for _, s := range supported {
+		v, ok := q[s]
+		if !ok {
+			v = wildcard
+		}
+		if v > bestQ {
+			best, bestQ = s, v
+		}
+	}
+	return best
+}
@@ -0,0 +1,2 @@
+// Package health - liveness and readiness endpoints over registry of named dependency checks
+package health
@@ -0,0 +1,204 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status values of report and results
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// ErrDraining - readiness result while shutting down
+var ErrDraining = errors.New("shutting down")
+
+// CheckFunc - dependency check, nil means healthy
+type CheckFunc func(ctx context.Context) error
+
+// Result - outcome of single check
+type Result struct {
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Duration string    `json:"duration"`
+	Checked  time.Time `json:"checked_at"`
+}
+
+// Report - readiness response body
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+
+	mu     sync.Mutex
+	result Result
+	// running - call to dependency shared by concurrent probes, nil when none runs
+	running *call
+}
+
+// call - single run of check, result is set when done is closed
+type call struct {
+	done   chan struct{}
+	result Result
+}
+
+// Registry - named checks run by readiness probe
+type Registry struct {
+	cacheTTL time.Duration
+	draining atomic.Bool
+
+	mu     sync.RWMutex
+	checks []*check
+}
+
+// New - constructor, results are reused for cacheTTL, zero runs checks on every probe
+func New(cacheTTL time.Duration) *Registry {
+	return &Registry{cacheTTL: cacheTTL}
+}
+
+// Register - adds check, zero timeout leaves it bounded by probe request only
+func (r *Registry) Register(name string, timeout time.Duration, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, &check{name: name, timeout: timeout, fn: fn})
+}
+
+// Drain - makes readiness fail regardless of checks, called when shutdown begins
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Run - runs checks concurrently, each within its timeout, reusing results younger than cache ttl
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.checks
+	r.mu.RUnlock()
+
+	rep := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks)+1)}
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *check) {
+			defer wg.Done()
+			results[i] = c.run(ctx, r.cacheTTL)
+		}(i, c)
+	}
+	wg.Wait()
+	for i, c := range checks {
+		rep.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			rep.Status = StatusFail
+		}
+	}
+	if r.draining.Load() {
+		rep.Status = StatusFail
+		rep.Checks["shutdown"] = Result{Status: StatusFail, Error: ErrDraining.Error(), Duration: "0s", Checked: time.Now()}
+	}
+	return rep
+}
+
+// run - cached result or result of shared call, probe waits for it until its ctx is done
+func (c *check) run(ctx context.Context, ttl time.Duration) Result {
+	start := time.Now()
+	if err := ctx.Err(); err != nil {
+		return Result{Status: StatusFail, Error: err.Error(), Duration: "0s", Checked: start}
+	}
+	c.mu.Lock()
+	if ttl > 0 && time.Since(c.result.Checked) < ttl {
+		res := c.result
+		c.mu.Unlock()
+		return res
+	}
+	cl := c.running
+	if cl == nil {
+		cl = &call{done: make(chan struct{})}
+		c.running = cl
+		// call outlives probe which started it, others may wait for it
+		go c.call(context.WithoutCancel(ctx), cl)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.result
+	case <-ctx.Done():
+		return Result{Status: StatusFail, Error: ctx.Err().Error(), Duration: time.Since(start).String(), Checked: time.Now()}
+	}
+}
+
+// call - calls dependency within timeout and caches result
+func (c *check) call(ctx context.Context, cl *call) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.fn(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// check ignoring ctx is abandoned
+		err = ctx.Err()
+	}
+	res := Result{Status: StatusOK, Duration: time.Since(start).String(), Checked: time.Now()}
+	if err != nil {
+		res.Status, res.Error = StatusFail, err.Error()
+	}
+	c.mu.Lock()
+	c.result, c.running = res, nil
+	c.mu.Unlock()
+	cl.result = res
+	close(cl.done)
+}
+
+// ServeHTTP - readiness handler, 200 when all checks pass, 503 otherwise, with result of each check
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rep := r.Run(req.Context())
+	code := http.StatusOK
+	if rep.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	write(w, code, rep)
+}
+
+// Summary - readiness handler for untrusted clients, answers like ServeHTTP with overall status only
+// as check errors may reveal hosts and users of dependencies
+func (r *Registry) Summary() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rep := r.Run(req.Context())
+		code := http.StatusOK
+		if rep.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		write(w, code, Report{Status: rep.Status})
+	})
+}
+
+// Live - liveness handler, answers 200 while process serves requests
+func Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		write(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+func write(w http.ResponseWriter, code int, rep Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(rep)
+}
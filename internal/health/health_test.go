@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readyz(t *testing.T, h http.Handler) (int, Report) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var rep Report
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&rep))
+	return w.Code, rep
+}
+
+func TestRegistry(t *testing.T) {
+	r := New(0)
+	r.Register("db", time.Second, func(ctx context.Context) error { return nil })
+	r.Register("slow", 20*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	r.Register("stuck", 20*time.Millisecond, func(ctx context.Context) error {
+		select {}
+	})
+
+	code, rep := readyz(t, r)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, rep.Status)
+	assert.Equal(t, StatusOK, rep.Checks["db"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), rep.Checks["slow"].Error)
+	assert.Equal(t, context.DeadlineExceeded.Error(), rep.Checks["stuck"].Error, "check ignoring ctx")
+
+	r = New(0)
+	r.Register("db", time.Second, func(ctx context.Context) error { return errors.New("connection refused") })
+	code, rep = readyz(t, r)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "connection refused", rep.Checks["db"].Error)
+}
+
+func TestRegistry_Cache(t *testing.T) {
+	r := New(time.Hour)
+	var calls atomic.Int32
+	r.Register("db", 0, func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		code, _ := readyz(t, r)
+		assert.Equal(t, http.StatusOK, code)
+	}
+	assert.Equal(t, int32(1), calls.Load(), "cached result reused")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	uncached := New(time.Hour)
+	uncached.Register("db", 0, func(ctx context.Context) error {
+		calls.Add(1)
+		return ctx.Err()
+	})
+	assert.Equal(t, StatusFail, uncached.Run(ctx).Status)
+	assert.Equal(t, StatusOK, uncached.Run(context.Background()).Status, "result of cancelled probe not cached")
+}
+
+func TestRegistry_Hung(t *testing.T) {
+	r := New(0)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	r.Register("db", 0, func(ctx context.Context) error {
+		calls.Add(1)
+		<-release
+		return nil
+	})
+	probe := func() Report {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		return r.Run(ctx)
+	}
+	first := make(chan Report)
+	go func() { first <- r.Run(context.Background()) }()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		rep := probe()
+		assert.Less(t, time.Since(start), time.Second, "probe bounded by its own ctx")
+		assert.Equal(t, context.DeadlineExceeded.Error(), rep.Checks["db"].Error)
+	}
+	assert.Equal(t, int32(1), calls.Load(), "probes wait for running call")
+
+	close(release)
+	assert.Equal(t, StatusOK, (<-first).Status)
+	assert.Eventually(t, func() bool { return probe().Status == StatusOK }, time.Second, 10*time.Millisecond)
+}
+
+func TestRegistry_Drain(t *testing.T) {
+	r := New(0)
+	r.Register("db", 0, func(ctx context.Context) error { return nil })
+	code, rep := readyz(t, r)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, rep.Checks, 1)
+
+	r.Drain()
+	code, rep = readyz(t, r)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, ErrDraining.Error(), rep.Checks["shutdown"].Error)
+
+	w := httptest.NewRecorder()
+	Live().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "draining process is alive")
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
@@ -0,0 +1,3 @@
+// Package lifecycle - ordered graceful shutdown: steps run in reverse order of adding, like deferred calls,
+// each within its own timeout, and failure of any long-running component starts shutdown instead of killing the process.
+package lifecycle
@@ -0,0 +1,118 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/logger"
+)
+
+// Hook - shutdown step, should return when ctx is done
+type Hook func(ctx context.Context) error
+
+type step struct {
+	name    string
+	timeout time.Duration
+	hook    Hook
+}
+
+// Manager - runs shutdown steps in reverse order of adding, so resource opened first is closed last
+type Manager struct {
+	logger logger.Logger
+
+	mu    sync.Mutex
+	steps []step
+
+	failOnce sync.Once
+	failed   chan struct{}
+	cause    error
+}
+
+// New - constructor
+func New(l logger.Logger) *Manager {
+	return &Manager{logger: l, failed: make(chan struct{})}
+}
+
+// OnShutdown - adds step run by Shutdown before previously added ones, zero timeout waits indefinitely
+func (m *Manager) OnShutdown(name string, timeout time.Duration, hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.steps = append(m.steps, step{name: name, timeout: timeout, hook: hook})
+}
+
+// Fail - reports failure of long-running component, first one closes Failed and is returned by Shutdown
+func (m *Manager) Fail(name string, err error) {
+	m.failOnce.Do(func() {
+		m.cause = fmt.Errorf("%s: %w", name, err)
+		m.logger.WithFields(map[string]interface{}{
+			"component": name,
+			"error":     err,
+		}).Error("Component failed, shutting down")
+		close(m.failed)
+	})
+}
+
+// Failed - closed when some component failed
+func (m *Manager) Failed() <-chan struct{} {
+	return m.failed
+}
+
+// Go - runs fn in goroutine, error returned by fn is reported with Fail
+func (m *Manager) Go(name string, fn func() error) {
+	go func() {
+		if err := fn(); err != nil {
+			m.Fail(name, err)
+		}
+	}()
+}
+
+// Shutdown - runs all steps, a step failing or running out of time does not stop later ones.
+// Returns component failure and step errors joined.
+func (m *Manager) Shutdown() error {
+	m.mu.Lock()
+	steps := m.steps
+	m.mu.Unlock()
+
+	var errs []error
+	select {
+	case <-m.failed:
+		errs = append(errs, m.cause)
+	default:
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		s := steps[i]
+		start := time.Now()
+		err := run(s)
+		l := m.logger.WithFields(map[string]interface{}{
+			"step":     s.name,
+			"duration": time.Since(start).String(),
+		})
+		if err != nil {
+			l.WithField("error", err).Error("Shutdown step failed")
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		l.Info("Shutdown step done")
+	}
+	return errors.Join(errs...)
+}
+
+// run - runs hook within step timeout, hook ignoring ctx is abandoned when time is out
+func run(s step) error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if s.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+	}
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.hook(ctx) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,73 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Shutdown(t *testing.T) {
+	m := New(logger.NewDummy())
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	add := func(name string, timeout time.Duration, err error, block bool) {
+		m.OnShutdown(name, timeout, func(ctx context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			if block {
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			return err
+		})
+	}
+	add("close", 0, nil, false)
+	m.OnShutdown("stuck", 20*time.Millisecond, func(ctx context.Context) error {
+		select {}
+	})
+	add("flush", 0, errors.New("disk full"), false)
+	add("drain", 20*time.Millisecond, nil, true)
+	add("stop", 0, nil, false)
+
+	err := m.Shutdown()
+	mu.Lock()
+	assert.Equal(t, []string{"stop", "drain", "flush", "close"}, order)
+	mu.Unlock()
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "drain: ")
+	assert.ErrorContains(t, err, "flush: disk full")
+	assert.ErrorContains(t, err, "stuck: ")
+	assert.NotContains(t, err.Error(), "close")
+}
+
+func TestManager_Fail(t *testing.T) {
+	m := New(logger.NewDummy())
+	select {
+	case <-m.Failed():
+		t.Fatal("failed before any failure")
+	default:
+	}
+	assert.NoError(t, m.Shutdown())
+
+	cause := errors.New("address in use")
+	m.Go("server", func() error { return cause })
+	m.Go("admin", func() error { return nil })
+	select {
+	case <-m.Failed():
+	case <-time.After(time.Second):
+		t.Fatal("failure not reported")
+	}
+	m.Fail("other", errors.New("later"))
+	err := m.Shutdown()
+	assert.ErrorIs(t, err, cause)
+	assert.ErrorContains(t, err, "server: address in use")
+	assert.NotContains(t, err.Error(), "later")
+}
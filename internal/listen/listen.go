@@ -0,0 +1,142 @@
+// Package listen opens sockets described by config.ListenerConfig:
+// tcp addresses, unix domain sockets and sockets passed by systemd socket activation.
+package listen
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Stas9132/shortener/config"
+)
+
+// listenFdsStart - first file descriptor passed by systemd
+const listenFdsStart = 3
+
+// activation - sockets passed to the process, each can be opened once
+var activation struct {
+	once  sync.Once
+	mu    sync.Mutex
+	files []*os.File
+	names []string
+	err   error
+}
+
+// Open - listener for lc
+func Open(lc config.ListenerConfig) (net.Listener, error) {
+	switch lc.Network {
+	case "tcp":
+		return net.Listen("tcp", lc.Address)
+	case "unix":
+		return unix(lc)
+	case "systemd":
+		return systemd(lc.Address)
+	default:
+		return nil, fmt.Errorf("unknown network %q", lc.Network)
+	}
+}
+
+// unix - listens on socket file replacing stale one left by previous run
+func unix(lc config.ListenerConfig) (net.Listener, error) {
+	mode, err := lc.SocketMode()
+	if err != nil {
+		return nil, err
+	}
+	if st, err := os.Lstat(lc.Address); err == nil && st.Mode()&os.ModeSocket != 0 {
+		if c, err := net.Dial("unix", lc.Address); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("socket %s is in use", lc.Address)
+		}
+		if err = os.Remove(lc.Address); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", lc.Address)
+	if err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		if err = os.Chmod(lc.Address, mode); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// systemd - passed socket by LISTEN_FDNAMES name or zero-based index
+func systemd(name string) (net.Listener, error) {
+	activation.once.Do(func() {
+		var n int
+		n, activation.names, activation.err = parseEnv(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"))
+		for i := 0; i < n; i++ {
+			activation.files = append(activation.files, os.NewFile(uintptr(listenFdsStart+i), "LISTEN_FD_"+strconv.Itoa(listenFdsStart+i)))
+		}
+		// sockets are not meant for child processes
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	})
+	if activation.err != nil {
+		return nil, activation.err
+	}
+
+	activation.mu.Lock()
+	defer activation.mu.Unlock()
+	i := index(activation.names, name)
+	if i < 0 || i >= len(activation.files) {
+		return nil, fmt.Errorf("socket %q is not passed by systemd", name)
+	}
+	f := activation.files[i]
+	if f == nil {
+		return nil, fmt.Errorf("socket %q is already in use", name)
+	}
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	// listener holds its own descriptor
+	f.Close()
+	activation.files[i] = nil
+	return l, nil
+}
+
+// parseEnv - number and names of sockets passed by systemd, zero when they are meant for another process
+func parseEnv(pid, fds, names string) (int, []string, error) {
+	if pid == "" || fds == "" {
+		return 0, nil, nil
+	}
+	p, err := strconv.Atoi(pid)
+	if err != nil {
+		return 0, nil, errors.New("invalid LISTEN_PID")
+	}
+	if p != os.Getpid() {
+		return 0, nil, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return 0, nil, errors.New("invalid LISTEN_FDS")
+	}
+	var ns []string
+	if names != "" {
+		ns = strings.Split(names, ":")
+	}
+	return n, ns, nil
+}
+
+// index - position of name among names, name may be the index itself
+func index(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	if i, err := strconv.Atoi(name); err == nil {
+		return i
+	}
+	return -1
+}
@@ -0,0 +1,80 @@
+package listen
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen_TCP(t *testing.T) {
+	l, err := Open(config.ListenerConfig{Network: "tcp", Address: "127.0.0.1:0"})
+	require.NoError(t, err)
+	defer l.Close()
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	c.Close()
+
+	_, err = Open(config.ListenerConfig{Network: "udp", Address: ":0"})
+	assert.Error(t, err)
+}
+
+func TestOpen_Unix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.sock")
+	lc := config.ListenerConfig{Network: "unix", Address: path, Mode: "0660"}
+	l, err := Open(lc)
+	require.NoError(t, err)
+	st, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), st.Mode().Perm())
+
+	_, err = Open(lc)
+	assert.ErrorContains(t, err, "in use")
+
+	// stale socket file of crashed process
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+	l, err = Open(lc)
+	require.NoError(t, err, "stale socket replaced")
+	require.NoError(t, l.Close())
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "socket removed on close")
+}
+
+func TestParseEnv(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name            string
+		pid, fds, names string
+		want            int
+		wantNames       []string
+		wantErr         bool
+	}{
+		{name: "Not activated"},
+		{name: "Other process", pid: "1", fds: "2"},
+		{name: "Unnamed", pid: pid, fds: "2", want: 2},
+		{name: "Named", pid: pid, fds: "2", names: "http:admin", want: 2, wantNames: []string{"http", "admin"}},
+		{name: "Bad pid", pid: "x", fds: "1", wantErr: true},
+		{name: "Bad fds", pid: pid, fds: "-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, names, err := parseEnv(tt.pid, tt.fds, tt.names)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, n)
+			assert.Equal(t, tt.wantNames, names)
+		})
+	}
+	assert.Equal(t, 1, index([]string{"http", "admin"}, "admin"))
+	assert.Equal(t, 1, index(nil, "1"))
+	assert.Equal(t, -1, index([]string{"http"}, "grpc"))
+}
@@ -0,0 +1,42 @@
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+type ctxKey struct{}
+
+// holder keeps request logger shared by all derived contexts, so fields added by inner
+// middleware are visible to outer ones (e.g. access log)
+type holder struct {
+	mu    sync.RWMutex
+	entry Logger
+}
+
+// NewContext - returns ctx carrying request scoped logger derived from l with fields
+func NewContext(ctx context.Context, l Logger, fields Fields) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &holder{entry: l.WithFields(fields)})
+}
+
+// AddFields - adds fields to request scoped logger stored in ctx
+func AddFields(ctx context.Context, fields Fields) {
+	h, ok := ctx.Value(ctxKey{}).(*holder)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	h.entry = h.entry.WithFields(fields)
+	h.mu.Unlock()
+}
+
+// FromContext - returns request scoped logger stored in ctx or fallback
+func FromContext(ctx context.Context, fallback Logger) Logger {
+	h, ok := ctx.Value(ctxKey{}).(*holder)
+	if !ok {
+		return fallback
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.entry
+}
@@ -1,7 +1,5 @@
 package logger
 
-import "github.com/sirupsen/logrus"
-
 // Dummy - dummy logger
 type Dummy struct {
 }
@@ -42,9 +40,9 @@ func (d *Dummy) Error(...interface{}) {}
 func (d *Dummy) Errorf(string, ...interface{}) {}
 
 // WithField - dummy method
-func (d *Dummy) WithField(key string, value interface{}) *logrus.Entry {
-	return logrus.NewEntry(logger)
+func (d *Dummy) WithField(key string, value interface{}) Logger {
+	return d
 }
 
 // WithFields - dummy method
-func (d *Dummy) WithFields(fields logrus.Fields) *logrus.Entry { return logrus.NewEntry(logger) }
+func (d *Dummy) WithFields(fields Fields) Logger { return d }
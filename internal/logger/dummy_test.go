@@ -1,7 +1,6 @@
 package logger
 
 import (
-	"github.com/sirupsen/logrus"
 	"reflect"
 	"testing"
 )
@@ -199,7 +198,7 @@ func TestDummy_WithField(t *testing.T) {
 	tests := []struct {
 		name string
 		args args
-		want *logrus.Entry
+		want Logger
 	}{
 		// TODO: Add test cases.
 	}
@@ -215,12 +214,12 @@ func TestDummy_WithField(t *testing.T) {
 
 func TestDummy_WithFields(t *testing.T) {
 	type args struct {
-		fields logrus.Fields
+		fields Fields
 	}
 	tests := []struct {
 		name string
 		args args
-		want *logrus.Entry
+		want Logger
 	}{
 		// TODO: Add test cases.
 	}
@@ -2,11 +2,12 @@ package logger_test
 
 import (
 	"context"
+	"github.com/Stas9132/shortener/config"
 	"github.com/Stas9132/shortener/internal/logger"
 )
 
 func Example() {
-	l, err := logger.NewLogrusLogger(context.Background())
+	l, err := logger.NewLogrusLogger(context.Background(), config.Default())
 	if err != nil {
 		panic(err)
 	}
@@ -4,16 +4,12 @@ import (
 	"context"
 	"fmt"
 	"github.com/Stas9132/shortener/config"
-
-	"github.com/sirupsen/logrus"
+	"os"
+	"sync/atomic"
 )
 
-var logger = logrus.New()
-
-// LogrusLogger - Mediator
-type LogrusLogger struct {
-	*logrus.Logger
-}
+// Fields - structured fields attached to log message
+type Fields map[string]interface{}
 
 // Logger - interface to logger package
 type Logger interface {
@@ -27,8 +23,8 @@ type Logger interface {
 	Warnf(string, ...interface{})
 	Error(...interface{})
 	Errorf(string, ...interface{})
-	WithField(key string, value interface{}) *logrus.Entry
-	WithFields(fields logrus.Fields) *logrus.Entry
+	WithField(key string, value interface{}) Logger
+	WithFields(fields Fields) Logger
 }
 
 // Log will log a message at the level given as parameter.
@@ -37,116 +33,97 @@ type Log func(l ...interface{})
 // Logf will log a format message at the level given as parameter.
 type Logf func(s string, l ...interface{})
 
-// WithField, WithFields - static methods
-var WithField,
-	WithFields = logger.WithField,
-	logger.WithFields
+type stdHolder struct {
+	Logger
+}
 
-// Trace logs at the Trace level
-var Trace,
-	// Debug logs at the Debug level
-	Debug,
-	// Info logs at the Info level
-	Info,
-	// Warn logs at the Warn level
-	Warn,
-	// Error logs at the Error level
-	Error,
-	Print Log = logger.Trace,
-	logger.Debug,
-	logger.Info,
-	logger.Warn,
-	logger.Error,
-	func(l ...interface{}) {
-		fmt.Println(l...)
-	}
+var std atomic.Pointer[stdHolder]
 
-// Tracef logs at the trace level with formatting
-var Tracef,
-	// Debugf logs at the debug level with formatting
-	Debugf,
-	// Infof logs at the info level with formatting
-	Infof,
-	// Warnf logs at the warn level with formatting
-	Warnf,
-	// Errorf logs at the error level with formatting
-	Errorf,
-	Printf Logf = logger.Tracef,
-	logger.Debugf,
-	logger.Infof,
-	logger.Warnf,
-	logger.Errorf,
-	func(s string, l ...interface{}) {
-		fmt.Printf(s, l...)
-		fmt.Printf("\n")
-	}
+func init() {
+	std.Store(&stdHolder{newLogrusLogger(os.Stderr, "text")})
+}
+
+// Default - returns package level logger
+func Default() Logger {
+	return std.Load().Logger
+}
 
-// NewLogrusLogger - Creates a new logger.
-func NewLogrusLogger(ctx context.Context) (*LogrusLogger, error) {
-	lvl, err := logrus.ParseLevel(config.C.LogLevel)
+// SetDefault - replaces package level logger used by static functions
+func SetDefault(l Logger) {
+	std.Store(&stdHolder{l})
+}
+
+// New - creates logger of backend selected by cfg.LogBackend and makes it default
+func New(ctx context.Context, cfg config.Config) (Logger, error) {
+	var l Logger
+	var err error
+	switch cfg.LogBackend {
+	case "", "logrus":
+		l, err = NewLogrusLogger(ctx, cfg)
+	case "slog":
+		l, err = NewSlogLogger(ctx, cfg)
+	default:
+		err = fmt.Errorf("unknown log backend: %s", cfg.LogBackend)
+	}
 	if err != nil {
 		return nil, err
 	}
-	logger.SetLevel(lvl)
-	return &LogrusLogger{logger}, nil
+	SetDefault(l)
+	return l, nil
 }
 
-// Trace will log a message at the trace level.
-func (l *LogrusLogger) Trace(args ...interface{}) {
-	l.Logger.Trace(args...)
+// SetLevel - changes level of package level logger, backend must support it
+func SetLevel(level string) error {
+	l, ok := Default().(interface{ SetLevel(string) error })
+	if !ok {
+		return fmt.Errorf("logger %T does not support level change", Default())
+	}
+	return l.SetLevel(level)
 }
 
-// Tracef will log a format message at the trace level.
-func (l *LogrusLogger) Tracef(fmt string, args ...interface{}) {
-	l.Logger.Tracef(fmt, args...)
-}
+// WithField adds field to the message of package level logger
+func WithField(key string, value interface{}) Logger { return Default().WithField(key, value) }
 
-// Debug will log a message at the debug level.
-func (l *LogrusLogger) Debug(args ...interface{}) {
-	l.Logger.Debug(args...)
-}
+// WithFields adds fields to the message of package level logger
+func WithFields(fields Fields) Logger { return Default().WithFields(fields) }
 
-// Debugf will log a format message at the debug level.
-func (l *LogrusLogger) Debugf(fmt string, args ...interface{}) {
-	l.Logger.Debugf(fmt, args...)
-}
+// Trace logs at the Trace level
+func Trace(args ...interface{}) { Default().Trace(args...) }
 
-// Info will log a message at the info level.
-func (l *LogrusLogger) Info(args ...interface{}) {
-	l.Logger.Info(args...)
-}
+// Debug logs at the Debug level
+func Debug(args ...interface{}) { Default().Debug(args...) }
 
-// Infof will log a format message at the info level.
-func (l *LogrusLogger) Infof(fmt string, args ...interface{}) {
-	l.Logger.Infof(fmt, args...)
-}
+// Info logs at the Info level
+func Info(args ...interface{}) { Default().Info(args...) }
 
-// Warn will log a message at the warn level.
-func (l *LogrusLogger) Warn(args ...interface{}) {
-	l.Logger.Warn(args...)
-}
+// Warn logs at the Warn level
+func Warn(args ...interface{}) { Default().Warn(args...) }
 
-// Warnf will log a format message at the warn level.
-func (l *LogrusLogger) Warnf(fmt string, args ...interface{}) {
-	l.Logger.Warnf(fmt, args...)
-}
+// Error logs at the Error level
+func Error(args ...interface{}) { Default().Error(args...) }
 
-// Error will log a message at the error level.
-func (l *LogrusLogger) Error(args ...interface{}) {
-	l.Logger.Error(args...)
-}
+// Tracef logs at the trace level with formatting
+func Tracef(s string, args ...interface{}) { Default().Tracef(s, args...) }
 
-// Errorf will log a format message at the error level.
-func (l *LogrusLogger) Errorf(fmt string, args ...interface{}) {
-	l.Logger.Errorf(fmt, args...)
-}
+// Debugf logs at the debug level with formatting
+func Debugf(s string, args ...interface{}) { Default().Debugf(s, args...) }
+
+// Infof logs at the info level with formatting
+func Infof(s string, args ...interface{}) { Default().Infof(s, args...) }
+
+// Warnf logs at the warn level with formatting
+func Warnf(s string, args ...interface{}) { Default().Warnf(s, args...) }
+
+// Errorf logs at the error level with formatting
+func Errorf(s string, args ...interface{}) { Default().Errorf(s, args...) }
 
-// WithField will add field to the log message
-func (l *LogrusLogger) WithField(key string, value interface{}) *logrus.Entry {
-	return l.Logger.WithField(key, value)
+// Print writes message to stdout bypassing logger
+var Print Log = func(l ...interface{}) {
+	fmt.Println(l...)
 }
 
-// WithFields will add fields to the log message
-func (l *LogrusLogger) WithFields(fields logrus.Fields) *logrus.Entry {
-	return l.Logger.WithFields(fields)
+// Printf writes format message to stdout bypassing logger
+var Printf Logf = func(s string, l ...interface{}) {
+	fmt.Printf(s, l...)
+	fmt.Printf("\n")
 }
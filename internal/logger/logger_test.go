@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLoggerWithHandler(NewSlogHandler(&buf, "json", LevelTrace))
+	l.WithFields(Fields{"a": 1}).WithField("error", errors.New("boom")).Warnf("value %d", 42)
+	l.Trace("trace message")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &m))
+	assert.Equal(t, "WARN", m["level"])
+	assert.Equal(t, "value 42", m["msg"])
+	assert.Equal(t, float64(1), m["a"])
+	assert.Equal(t, "boom", m["error"])
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &m))
+	assert.Equal(t, "TRACE", m["level"])
+}
+
+func TestSlogLogger_Level(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLoggerWithHandler(NewSlogHandler(&buf, "text", slog.LevelWarn))
+	l.Info("skipped")
+	l.Error("written")
+	assert.NotContains(t, buf.String(), "skipped")
+	assert.Contains(t, buf.String(), "msg=written")
+
+	_, err := ParseSlogLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestLogrusLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := newLogrusLogger(&buf, "json")
+	l.WithField("k", "v").Info("message")
+	var m map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "info", m["level"])
+	assert.Equal(t, "message", m["msg"])
+	assert.Equal(t, "v", m["k"])
+}
+
+// severityRecorder - levelWriter remembering severity of each record
+type severityRecorder struct {
+	lines map[severity][]string
+}
+
+func (w *severityRecorder) Write(p []byte) (int, error) {
+	return w.WriteLevel(severityInfo, p)
+}
+
+func (w *severityRecorder) WriteLevel(s severity, p []byte) (int, error) {
+	w.lines[s] = append(w.lines[s], string(p))
+	return len(p), nil
+}
+
+func TestLevelWriter(t *testing.T) {
+	backends := map[string]func(w io.Writer) Logger{
+		"logrus": func(w io.Writer) Logger {
+			l := newLogrusLogger(w, "text")
+			l.Logger.SetLevel(logrus.TraceLevel)
+			return l
+		},
+		"slog": func(w io.Writer) Logger {
+			return NewSlogLoggerWithHandler(NewSlogHandler(w, "text", LevelTrace))
+		},
+	}
+	for name, newLogger := range backends {
+		newLogger := newLogger
+		t.Run(name, func(t *testing.T) {
+			w := &severityRecorder{lines: make(map[severity][]string)}
+			l := newLogger(w).WithField("k", "v")
+			l.Error("e")
+			l.Warn("w")
+			l.Info("i")
+			l.Debug("d")
+			l.Trace("t")
+			for s, n := range map[severity]int{severityError: 1, severityWarning: 1, severityInfo: 1, severityDebug: 2} {
+				require.Len(t, w.lines[s], n)
+				assert.Contains(t, w.lines[s][0], "k=v")
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder()
+	child := r.WithField("a", 1)
+	child.WithFields(Fields{"b": 2}).Errorf("e%d", 1)
+	r.Info("plain")
+
+	assert.Equal(t, []Entry{
+		{Level: "error", Message: "e1", Fields: Fields{"a": 1, "b": 2}},
+		{Level: "info", Message: "plain", Fields: Fields{}},
+	}, r.Entries())
+	r.Reset()
+	assert.Empty(t, r.Entries())
+}
+
+func TestSetLevel(t *testing.T) {
+	saved := Default()
+	defer SetDefault(saved)
+
+	var buf bytes.Buffer
+	l := newLogrusLogger(&buf, "text")
+	SetDefault(l)
+	require.NoError(t, SetLevel("error"))
+	l.WithField("k", "v").Info("skipped")
+	assert.Empty(t, buf.String())
+	assert.Error(t, SetLevel("verbose"))
+
+	level := new(slog.LevelVar)
+	sl := NewSlogLoggerWithHandler(NewSlogHandler(&buf, "text", level))
+	SetDefault(sl)
+	assert.Error(t, SetLevel("debug"), "fixed handler level")
+	sl.level = level
+	child := sl.WithField("k", "v")
+	require.NoError(t, SetLevel("error"))
+	child.Warn("skipped")
+	assert.Empty(t, buf.String())
+
+	SetDefault(NewRecorder())
+	assert.Error(t, SetLevel("debug"))
+}
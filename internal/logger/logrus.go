@@ -0,0 +1,142 @@
+package logger
+
+import (
+	"context"
+	"github.com/Stas9132/shortener/config"
+	"io"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogrusLogger - adapter of logrus
+type LogrusLogger struct {
+	*logrus.Entry
+}
+
+func newLogrusLogger(w io.Writer, format string) *LogrusLogger {
+	l := logrus.New()
+	if lw, ok := w.(levelWriter); ok {
+		l.SetOutput(io.Discard)
+		l.AddHook(levelHook{lw})
+	} else {
+		l.SetOutput(w)
+	}
+	if format == "json" {
+		l.SetFormatter(&logrus.JSONFormatter{})
+	}
+	return &LogrusLogger{logrus.NewEntry(l)}
+}
+
+// levelHook - writes formatted entries to levelWriter at severity of their level
+type levelHook struct {
+	w levelWriter
+}
+
+// Levels - method
+func (h levelHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire - method
+func (h levelHook) Fire(e *logrus.Entry) error {
+	b, err := e.Logger.Formatter.Format(e)
+	if err != nil {
+		return err
+	}
+	s := severityDebug
+	switch {
+	case e.Level <= logrus.ErrorLevel:
+		s = severityError
+	case e.Level == logrus.WarnLevel:
+		s = severityWarning
+	case e.Level == logrus.InfoLevel:
+		s = severityInfo
+	}
+	_, err = h.w.WriteLevel(s, b)
+	return err
+}
+
+// NewLogrusLogger - Creates a new logger.
+func NewLogrusLogger(ctx context.Context, cfg config.Config) (*LogrusLogger, error) {
+	lvl, err := logrus.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	w, err := openOutput(cfg)
+	if err != nil {
+		return nil, err
+	}
+	l := newLogrusLogger(w, cfg.LogFormat)
+	l.Logger.SetLevel(lvl)
+	return l, nil
+}
+
+// SetLevel - changes level of logger and all loggers derived from it
+func (l *LogrusLogger) SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.Logger.SetLevel(lvl)
+	return nil
+}
+
+// Trace will log a message at the trace level.
+func (l *LogrusLogger) Trace(args ...interface{}) {
+	l.Entry.Trace(args...)
+}
+
+// Tracef will log a format message at the trace level.
+func (l *LogrusLogger) Tracef(fmt string, args ...interface{}) {
+	l.Entry.Tracef(fmt, args...)
+}
+
+// Debug will log a message at the debug level.
+func (l *LogrusLogger) Debug(args ...interface{}) {
+	l.Entry.Debug(args...)
+}
+
+// Debugf will log a format message at the debug level.
+func (l *LogrusLogger) Debugf(fmt string, args ...interface{}) {
+	l.Entry.Debugf(fmt, args...)
+}
+
+// Info will log a message at the info level.
+func (l *LogrusLogger) Info(args ...interface{}) {
+	l.Entry.Info(args...)
+}
+
+// Infof will log a format message at the info level.
+func (l *LogrusLogger) Infof(fmt string, args ...interface{}) {
+	l.Entry.Infof(fmt, args...)
+}
+
+// Warn will log a message at the warn level.
+func (l *LogrusLogger) Warn(args ...interface{}) {
+	l.Entry.Warn(args...)
+}
+
+// Warnf will log a format message at the warn level.
+func (l *LogrusLogger) Warnf(fmt string, args ...interface{}) {
+	l.Entry.Warnf(fmt, args...)
+}
+
+// Error will log a message at the error level.
+func (l *LogrusLogger) Error(args ...interface{}) {
+	l.Entry.Error(args...)
+}
+
+// Errorf will log a format message at the error level.
+func (l *LogrusLogger) Errorf(fmt string, args ...interface{}) {
+	l.Entry.Errorf(fmt, args...)
+}
+
+// WithField will add field to the log message
+func (l *LogrusLogger) WithField(key string, value interface{}) Logger {
+	return &LogrusLogger{l.Entry.WithField(key, value)}
+}
+
+// WithFields will add fields to the log message
+func (l *LogrusLogger) WithFields(fields Fields) Logger {
+	return &LogrusLogger{l.Entry.WithFields(logrus.Fields(fields))}
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"fmt"
+	"github.com/Stas9132/shortener/config"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// severity - importance of record for outputs keeping it apart from the text, e.g. syslog
+type severity int
+
+const (
+	severityDebug severity = iota
+	severityInfo
+	severityWarning
+	severityError
+	severityCount
+)
+
+// levelWriter - output writing each record at severity of its level
+type levelWriter interface {
+	io.Writer
+	WriteLevel(s severity, p []byte) (int, error)
+}
+
+// severityWriter - writes everything to levelWriter at fixed severity
+type severityWriter struct {
+	w levelWriter
+	s severity
+}
+
+// Write - method
+func (w severityWriter) Write(p []byte) (int, error) {
+	return w.w.WriteLevel(w.s, p)
+}
+
+var output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// openOutput opens log destination selected by cfg.LogOutput
+func openOutput(cfg config.Config) (io.Writer, error) {
+	var w io.Writer
+	switch cfg.LogOutput {
+	case "", "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	case "file":
+		fc := cfg.LogFile
+		f, err := NewRotatingFile(fc.Path, int64(fc.MaxSize), time.Duration(fc.MaxAge), fc.MaxBackups, fc.Compress)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	case "syslog":
+		s, err := NewSyslog(cfg.LogSyslogTag)
+		if err != nil {
+			return nil, err
+		}
+		w = s
+	default:
+		return nil, fmt.Errorf("unknown log output: %s", cfg.LogOutput)
+	}
+	output.mu.Lock()
+	output.w = w
+	output.mu.Unlock()
+	return w, nil
+}
+
+// Reopen - reopens log file, call on SIGHUP after external logrotate moved it
+func Reopen() error {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	if f, ok := output.w.(interface{ Reopen() error }); ok {
+		return f.Reopen()
+	}
+	return nil
+}
+
+// CloseOutput - flushes and closes log file or syslog connection
+func CloseOutput() error {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+	if c, ok := output.w.(io.Closer); ok && output.w != os.Stderr && output.w != os.Stdout {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Entry - log message captured by Recorder
+type Entry struct {
+	Level   string
+	Message string
+	Fields  Fields
+}
+
+type recorded struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// Recorder - logger capturing entries for assertions in tests
+type Recorder struct {
+	rec    *recorded
+	fields Fields
+}
+
+// NewRecorder - Creates a new recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{rec: &recorded{}}
+}
+
+// Entries - returns copy of captured entries
+func (r *Recorder) Entries() []Entry {
+	r.rec.mu.Lock()
+	defer r.rec.mu.Unlock()
+	return append([]Entry(nil), r.rec.entries...)
+}
+
+// Reset - drops captured entries
+func (r *Recorder) Reset() {
+	r.rec.mu.Lock()
+	r.rec.entries = nil
+	r.rec.mu.Unlock()
+}
+
+func (r *Recorder) add(level, msg string) {
+	f := make(Fields, len(r.fields))
+	for k, v := range r.fields {
+		f[k] = v
+	}
+	r.rec.mu.Lock()
+	r.rec.entries = append(r.rec.entries, Entry{Level: level, Message: msg, Fields: f})
+	r.rec.mu.Unlock()
+}
+
+// Trace records a message at the trace level.
+func (r *Recorder) Trace(args ...interface{}) { r.add("trace", fmt.Sprint(args...)) }
+
+// Tracef records a format message at the trace level.
+func (r *Recorder) Tracef(f string, args ...interface{}) { r.add("trace", fmt.Sprintf(f, args...)) }
+
+// Debug records a message at the debug level.
+func (r *Recorder) Debug(args ...interface{}) { r.add("debug", fmt.Sprint(args...)) }
+
+// Debugf records a format message at the debug level.
+func (r *Recorder) Debugf(f string, args ...interface{}) { r.add("debug", fmt.Sprintf(f, args...)) }
+
+// Info records a message at the info level.
+func (r *Recorder) Info(args ...interface{}) { r.add("info", fmt.Sprint(args...)) }
+
+// Infof records a format message at the info level.
+func (r *Recorder) Infof(f string, args ...interface{}) { r.add("info", fmt.Sprintf(f, args...)) }
+
+// Warn records a message at the warn level.
+func (r *Recorder) Warn(args ...interface{}) { r.add("warning", fmt.Sprint(args...)) }
+
+// Warnf records a format message at the warn level.
+func (r *Recorder) Warnf(f string, args ...interface{}) { r.add("warning", fmt.Sprintf(f, args...)) }
+
+// Error records a message at the error level.
+func (r *Recorder) Error(args ...interface{}) { r.add("error", fmt.Sprint(args...)) }
+
+// Errorf records a format message at the error level.
+func (r *Recorder) Errorf(f string, args ...interface{}) { r.add("error", fmt.Sprintf(f, args...)) }
+
+// WithField returns recorder adding field to recorded messages
+func (r *Recorder) WithField(key string, value interface{}) Logger {
+	return r.WithFields(Fields{key: value})
+}
+
+// WithFields returns recorder adding fields to recorded messages
+func (r *Recorder) WithFields(fields Fields) Logger {
+	f := make(Fields, len(r.fields)+len(fields))
+	for k, v := range r.fields {
+		f[k] = v
+	}
+	for k, v := range fields {
+		f[k] = v
+	}
+	return &Recorder{rec: r.rec, fields: f}
+}
@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "2006-01-02T15-04-05.000000000"
+
+// RotatingFile - log file rotated when it exceeds MaxSize bytes or gets older than MaxAge.
+// Old segments are renamed to <name>-<time><ext>, optionally gzip compressed and
+// removed above MaxBackups. Writes are serialized with rotation so no lines are lost.
+type RotatingFile struct {
+	Path       string
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	opened  time.Time
+	pending sync.WaitGroup
+}
+
+// NewRotatingFile - opens or creates log file
+func NewRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*RotatingFile, error) {
+	f := &RotatingFile{Path: path, MaxSize: maxSize, MaxAge: maxAge, MaxBackups: maxBackups, Compress: compress}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	st, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, st.Size(), st.ModTime()
+	if f.size == 0 {
+		f.opened = time.Now()
+	}
+	return nil
+}
+
+// Write - writes p rotating file first when it would exceed limits
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.size > 0 && ((f.MaxSize > 0 && f.size+int64(len(p)) > f.MaxSize) ||
+		(f.MaxAge > 0 && time.Since(f.opened) > f.MaxAge)) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate - forces rotation of current file
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rotate()
+}
+
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	ext := filepath.Ext(f.Path)
+	base := strings.TrimSuffix(f.Path, ext) + "-" + time.Now().Format(backupTimeFormat)
+	backup := base + ext
+	for i := 1; fileExists(backup) || fileExists(backup+".gz"); i++ {
+		backup = base + "." + strconv.Itoa(i) + ext
+	}
+	if err := os.Rename(f.Path, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.pending.Add(1)
+	go func() {
+		defer f.pending.Done()
+		if f.Compress {
+			_ = compressFile(backup)
+		}
+		f.cleanup()
+	}()
+	return nil
+}
+
+// Reopen - closes and reopens file at Path, used after external rotation (e.g. logrotate on SIGHUP)
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	return f.open()
+}
+
+// Close - waits for background compression and closes file
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pending.Wait()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// backups returns rotated segments sorted from newest to oldest
+func (f *RotatingFile) backups() []string {
+	ext := filepath.Ext(f.Path)
+	matches, _ := filepath.Glob(strings.TrimSuffix(f.Path, ext) + "-*" + ext + "*")
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+	return matches
+}
+
+func (f *RotatingFile) cleanup() {
+	if f.MaxBackups <= 0 {
+		return
+	}
+	for i, name := range f.backups() {
+		if i >= f.MaxBackups {
+			_ = os.Remove(name)
+		}
+	}
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
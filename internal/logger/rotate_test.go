@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackups int
+	}{
+		{name: "Keep all", maxBackups: 0},
+		{name: "Keep two", maxBackups: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testRotatingFile(t, tt.maxBackups)
+		})
+	}
+}
+
+func testRotatingFile(t *testing.T, maxBackups int) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(path, 100, 0, maxBackups, true)
+	require.NoError(t, err)
+
+	line := strings.Repeat("x", 39) + "\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.Write([]byte(line))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, f.Close())
+
+	backups := f.backups()
+	if maxBackups > 0 {
+		assert.LessOrEqual(t, len(backups), maxBackups)
+	}
+	total := 0
+	for _, b := range backups {
+		assert.True(t, strings.HasSuffix(b, ".log.gz"), b)
+		zf, err := os.Open(b)
+		require.NoError(t, err)
+		zr, err := gzip.NewReader(zf)
+		require.NoError(t, err)
+		data, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		zf.Close()
+		assert.Equal(t, 0, len(data)%len(line))
+		assert.LessOrEqual(t, len(data), 100)
+		total += len(data)
+	}
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(data), 100)
+	total += len(data)
+	if maxBackups == 0 {
+		assert.Equal(t, 20*len(line), total)
+	}
+}
+
+func TestRotatingFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(path, 0, 0, 0, false)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("before\n"))
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, f.Reopen())
+	_, err = f.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	old, err := os.ReadFile(path + ".1")
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal([]byte("before\n"), old))
+	cur, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(cur))
+}
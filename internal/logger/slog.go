@@ -0,0 +1,203 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Stas9132/shortener/config"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// LevelTrace - slog level used for trace messages
+const LevelTrace = slog.Level(-8)
+
+// SlogLogger - log/slog backend
+type SlogLogger struct {
+	l     *slog.Logger
+	level *slog.LevelVar
+}
+
+// ParseSlogLevel - converts level name used in config to slog level
+func ParseSlogLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "trace":
+		return LevelTrace, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error", "fatal", "panic":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("not a valid slog Level: %q", s)
+}
+
+// NewSlogHandler - creates json or text handler writing to w
+func NewSlogHandler(w io.Writer, format string, level slog.Leveler) slog.Handler {
+	if lw, ok := w.(levelWriter); ok {
+		var h severityHandler
+		for s := range h {
+			h[s] = NewSlogHandler(severityWriter{w: lw, s: severity(s)}, format, level)
+		}
+		return h
+	}
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey {
+				if lvl, ok := a.Value.Any().(slog.Level); ok && lvl == LevelTrace {
+					a.Value = slog.StringValue("TRACE")
+				}
+			}
+			return a
+		},
+	}
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// severityHandler - passes each record to handler writing at severity of record level
+type severityHandler [severityCount]slog.Handler
+
+// Enabled - method
+func (h severityHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h[severityInfo].Enabled(ctx, level)
+}
+
+// Handle - method
+func (h severityHandler) Handle(ctx context.Context, r slog.Record) error {
+	s := severityDebug
+	switch {
+	case r.Level >= slog.LevelError:
+		s = severityError
+	case r.Level >= slog.LevelWarn:
+		s = severityWarning
+	case r.Level >= slog.LevelInfo:
+		s = severityInfo
+	}
+	return h[s].Handle(ctx, r)
+}
+
+// WithAttrs - method
+func (h severityHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	for s := range h {
+		h[s] = h[s].WithAttrs(attrs)
+	}
+	return h
+}
+
+// WithGroup - method
+func (h severityHandler) WithGroup(name string) slog.Handler {
+	for s := range h {
+		h[s] = h[s].WithGroup(name)
+	}
+	return h
+}
+
+// NewSlogLogger - Creates a new logger with output, level and format from cfg.
+func NewSlogLogger(ctx context.Context, cfg config.Config) (*SlogLogger, error) {
+	lvl, err := ParseSlogLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	w, err := openOutput(cfg)
+	if err != nil {
+		return nil, err
+	}
+	level := new(slog.LevelVar)
+	level.Set(lvl)
+	l := NewSlogLoggerWithHandler(NewSlogHandler(w, cfg.LogFormat, level))
+	l.level = level
+	return l, nil
+}
+
+// NewSlogLoggerWithHandler - Creates a new logger on top of h.
+func NewSlogLoggerWithHandler(h slog.Handler) *SlogLogger {
+	return &SlogLogger{l: slog.New(h)}
+}
+
+// SetLevel - changes level of logger and all loggers derived from it,
+// supported only by loggers created with NewSlogLogger
+func (l *SlogLogger) SetLevel(level string) error {
+	if l.level == nil {
+		return errors.New("slog handler level is fixed")
+	}
+	lvl, err := ParseSlogLevel(level)
+	if err != nil {
+		return err
+	}
+	l.level.Set(lvl)
+	return nil
+}
+
+func (l *SlogLogger) log(level slog.Level, msg string) {
+	l.l.Log(context.Background(), level, msg)
+}
+
+// Trace will log a message at the trace level.
+func (l *SlogLogger) Trace(args ...interface{}) { l.log(LevelTrace, fmt.Sprint(args...)) }
+
+// Tracef will log a format message at the trace level.
+func (l *SlogLogger) Tracef(f string, args ...interface{}) {
+	l.log(LevelTrace, fmt.Sprintf(f, args...))
+}
+
+// Debug will log a message at the debug level.
+func (l *SlogLogger) Debug(args ...interface{}) { l.log(slog.LevelDebug, fmt.Sprint(args...)) }
+
+// Debugf will log a format message at the debug level.
+func (l *SlogLogger) Debugf(f string, args ...interface{}) {
+	l.log(slog.LevelDebug, fmt.Sprintf(f, args...))
+}
+
+// Info will log a message at the info level.
+func (l *SlogLogger) Info(args ...interface{}) { l.log(slog.LevelInfo, fmt.Sprint(args...)) }
+
+// Infof will log a format message at the info level.
+func (l *SlogLogger) Infof(f string, args ...interface{}) {
+	l.log(slog.LevelInfo, fmt.Sprintf(f, args...))
+}
+
+// Warn will log a message at the warn level.
+func (l *SlogLogger) Warn(args ...interface{}) { l.log(slog.LevelWarn, fmt.Sprint(args...)) }
+
+// Warnf will log a format message at the warn level.
+func (l *SlogLogger) Warnf(f string, args ...interface{}) {
+	l.log(slog.LevelWarn, fmt.Sprintf(f, args...))
+}
+
+// Error will log a message at the error level.
+func (l *SlogLogger) Error(args ...interface{}) { l.log(slog.LevelError, fmt.Sprint(args...)) }
+
+// Errorf will log a format message at the error level.
+func (l *SlogLogger) Errorf(f string, args ...interface{}) {
+	l.log(slog.LevelError, fmt.Sprintf(f, args...))
+}
+
+// WithField will add field to the log message
+func (l *SlogLogger) WithField(key string, value interface{}) Logger {
+	return &SlogLogger{l: l.l.With(slogAttr(key, value)), level: l.level}
+}
+
+// WithFields will add fields to the log message
+func (l *SlogLogger) WithFields(fields Fields) Logger {
+	args := make([]any, 0, len(fields))
+	for k, v := range fields {
+		args = append(args, slogAttr(k, v))
+	}
+	return &SlogLogger{l: l.l.With(args...), level: l.level}
+}
+
+// slogAttr renders errors as strings, json handler would print them as {}
+func slogAttr(key string, value interface{}) slog.Attr {
+	if err, ok := value.(error); ok {
+		return slog.String(key, err.Error())
+	}
+	return slog.Any(key, value)
+}
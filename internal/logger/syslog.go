@@ -0,0 +1,41 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// syslogWriter - syslog connection sending records at severity of their level
+type syslogWriter struct {
+	*syslog.Writer
+}
+
+// NewSyslog - connects to local syslog daemon, records written without level are sent as info
+func NewSyslog(tag string) (io.WriteCloser, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return syslogWriter{w}, nil
+}
+
+// WriteLevel - method
+func (w syslogWriter) WriteLevel(s severity, p []byte) (int, error) {
+	var err error
+	switch s {
+	case severityError:
+		err = w.Err(string(p))
+	case severityWarning:
+		err = w.Warning(string(p))
+	case severityDebug:
+		err = w.Debug(string(p))
+	default:
+		err = w.Info(string(p))
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
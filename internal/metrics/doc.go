@@ -0,0 +1,2 @@
+// Package metrics - prometheus metrics of the service
+package metrics
@@ -0,0 +1,189 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "shortener"
+
+// Registry - registry of all service metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of handled http requests.",
+	}, []string{"route", "method", "status"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of handled http requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of storage operations.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"backend", "operation"})
+	storageErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_errors_total",
+		Help:      "Number of failed storage operations.",
+	}, []string{"backend", "operation"})
+	// DeleteQueue - number of keys waiting for asynchronous deletion
+	DeleteQueue = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "delete_queue_depth",
+		Help:      "Number of short urls waiting for asynchronous deletion.",
+	})
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_cache_requests_total",
+		Help:      "Storage cache lookups by result: hit, negative_hit or miss.",
+	}, []string{"operation", "result"})
+	cacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "storage_cache_entries",
+		Help:      "Number of entries in storage cache.",
+	})
+	bloomLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bloom_lookups_total",
+		Help:      "Bloom filter lookups by result: absent answered without storage, maybe passed to storage, bypass while filter is not trusted.",
+	}, []string{"result"})
+	bloomBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bloom_filter_bytes",
+		Help:      "Memory taken by Bloom filter of existing codes.",
+	})
+	bloomItems = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "bloom_filter_items",
+		Help:      "Number of codes added to Bloom filter.",
+	})
+	dbReads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_reads_total",
+		Help:      "Database reads by target: replica, primary without replicas, pinned primary after user write, fallback primary while replicas are down.",
+	}, []string{"target"})
+	replicaUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_up",
+		Help:      "Whether database read replica passes health check.",
+	}, []string{"replica"})
+	buildInfo = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build information, value is always 1.",
+	}, []string{"version", "date", "commit"})
+)
+
+func init() {
+	Registry.MustRegister(
+		requestsTotal,
+		requestDuration,
+		storageDuration,
+		storageErrors,
+		DeleteQueue,
+		cacheRequests,
+		cacheEntries,
+		bloomLookups,
+		bloomBytes,
+		bloomItems,
+		dbReads,
+		replicaUp,
+		buildInfo,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// SetBuildInfo - publishes build information
+func SetBuildInfo(version, date, commit string) {
+	buildInfo.WithLabelValues(version, date, commit).Set(1)
+}
+
+// RegisterDB - publishes connection pool statistics of db
+func RegisterDB(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// ObserveStorage - records latency and result of storage operation started at start
+func ObserveStorage(backend, operation string, start time.Time, err error) {
+	storageDuration.WithLabelValues(backend, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		storageErrors.WithLabelValues(backend, operation).Inc()
+	}
+}
+
+// ObserveCache - counts storage cache lookup with its result
+func ObserveCache(operation, result string) {
+	cacheRequests.WithLabelValues(operation, result).Inc()
+}
+
+// SetCacheEntries - publishes storage cache size
+func SetCacheEntries(n int) {
+	cacheEntries.Set(float64(n))
+}
+
+// ObserveBloom - counts Bloom filter lookup with its result
+func ObserveBloom(result string) {
+	bloomLookups.WithLabelValues(result).Inc()
+}
+
+// SetBloomFilter - publishes Bloom filter size
+func SetBloomFilter(bytes, items int) {
+	bloomBytes.Set(float64(bytes))
+	bloomItems.Set(float64(items))
+}
+
+// ObserveDBRead - counts database read with target it went to
+func ObserveDBRead(target string) {
+	dbReads.WithLabelValues(target).Inc()
+}
+
+// SetReplicaUp - publishes health of read replica
+func SetReplicaUp(replica string, up bool) {
+	v := 0.0
+	if up {
+		v = 1
+	}
+	replicaUp.WithLabelValues(replica).Set(v)
+}
+
+// Handler - http handler exposing metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware - counts requests and their latency per route pattern and status
+func Middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		h.ServeHTTP(ww, r)
+		code := ww.Status()
+		if code == 0 {
+			code = http.StatusOK
+		}
+
+		route := "unmatched"
+		if rc := chi.RouteContext(r.Context()); rc != nil && rc.RoutePattern() != "" {
+			route = rc.RoutePattern()
+		}
+		status := strconv.Itoa(code)
+		requestsTotal.WithLabelValues(route, r.Method, status).Inc()
+		requestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(t).Seconds())
+	})
+}
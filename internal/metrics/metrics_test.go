@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/{sn}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	for i := 0; i < 3; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc", nil))
+	}
+	assert.Equal(t, float64(3), testutil.ToFloat64(requestsTotal.WithLabelValues("/{sn}", http.MethodGet, "307")))
+}
+
+func TestMiddleware_Flusher(t *testing.T) {
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		require.True(t, ok, "streaming handlers keep flusher")
+		w.Write([]byte("a"))
+		f.Flush()
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, float64(1), testutil.ToFloat64(requestsTotal.WithLabelValues("unmatched", http.MethodGet, "200")))
+}
+
+func TestObserveStorage(t *testing.T) {
+	ObserveStorage("test", "load", time.Now(), nil)
+	ObserveStorage("test", "load", time.Now(), errors.New("fail"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(storageErrors.WithLabelValues("test", "load")))
+}
+
+func TestHandler(t *testing.T) {
+	SetBuildInfo("v1", "today", "abc")
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.Contains(w.Body.String(), `shortener_build_info{commit="abc",date="today",version="v1"} 1`))
+}
@@ -0,0 +1,166 @@
+package secure
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/Stas9132/shortener/config"
+)
+
+// Authority - certificate authority issuing server certificates
+type Authority struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// NewAuthority - creates self-signed CA valid for validity
+func NewAuthority(name string, validity time.Duration) (*Authority, error) {
+	tmpl := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		MaxPathLenZero:        true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	cert, key, err := create(tmpl, nil, nil, validity)
+	if err != nil {
+		return nil, err
+	}
+	return &Authority{Cert: cert, Key: key}, nil
+}
+
+// Issue - server certificate for hosts, DNS names or IP addresses, valid for validity
+func (a *Authority) Issue(hosts []string, validity time.Duration) (*x509.Certificate, crypto.Signer, error) {
+	tmpl, err := serverTemplate(hosts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return create(tmpl, a.Cert, a.Key, validity)
+}
+
+// serverTemplate - server certificate with hosts as SANs
+func serverTemplate(hosts []string) (*x509.Certificate, error) {
+	if len(hosts) == 0 {
+		return nil, errors.New("at least one host is required")
+	}
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0]},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	return tmpl, nil
+}
+
+// create signs tmpl with parent, self-signed when parent is nil, using new P-256 key
+func create(tmpl, parent *x509.Certificate, parentKey crypto.Signer, validity time.Duration) (*x509.Certificate, crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl.SerialNumber = serial
+	tmpl.NotBefore = time.Now().Add(-5 * time.Minute)
+	tmpl.NotAfter = time.Now().Add(validity)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+// WritePair - writes certificate readable by everyone and key readable by owner only.
+// Existing files are kept unless overwrite is set.
+func WritePair(certFile, keyFile string, cert *x509.Certificate, key crypto.Signer, overwrite bool) error {
+	kb, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err = writePEM(keyFile, "PRIVATE KEY", kb, 0600, overwrite); err != nil {
+		return err
+	}
+	return writePEM(certFile, "CERTIFICATE", cert.Raw, 0644, overwrite)
+}
+
+func writePEM(name, typ string, der []byte, perm os.FileMode, overwrite bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(name, flags, perm)
+	if err != nil {
+		return err
+	}
+	// umask does not narrow permissions of file that already existed
+	if err = f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err = pem.Encode(f, &pem.Block{Type: typ, Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SelfSigned - in-memory self-signed server certificate for hosts
+func SelfSigned(hosts []string, validity time.Duration) (tls.Certificate, error) {
+	tmpl, err := serverTemplate(hosts)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert, key, err := create(tmpl, nil, nil, validity)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}, nil
+}
+
+// Hosts - names the server is reachable by: loopback, listen address host and hosts of domain base urls
+func Hosts(c config.Config) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	add := func(h string) {
+		if h == "" || slices.Contains(hosts, h) {
+			return
+		}
+		if ip := net.ParseIP(h); ip != nil && ip.IsUnspecified() {
+			return
+		}
+		hosts = append(hosts, h)
+	}
+	if h, _, err := net.SplitHostPort(c.ServerAddress); err == nil {
+		add(h)
+	}
+	for _, d := range c.DomainList() {
+		if u, err := url.Parse(d.BaseURL); err == nil {
+			add(u.Hostname())
+		}
+	}
+	return hosts
+}
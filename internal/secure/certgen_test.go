@@ -0,0 +1,82 @@
+package secure
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthority_Issue(t *testing.T) {
+	ca, err := NewAuthority("test CA", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, ca.Cert.IsCA)
+
+	cert, key, err := ca.Issue([]string{"short.test", "127.0.0.1"}, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"short.test"}, cert.DNSNames)
+	assert.True(t, cert.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")))
+	assert.Equal(t, cert.PublicKey, key.Public())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	for _, host := range []string{"short.test", "127.0.0.1"} {
+		_, err = cert.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		assert.NoError(t, err, host)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "other.test", Roots: roots})
+	assert.Error(t, err)
+
+	_, _, err = ca.Issue(nil, time.Hour)
+	assert.Error(t, err, "no hosts")
+}
+
+func TestWritePair(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	ca, err := NewAuthority("test CA", time.Hour)
+	require.NoError(t, err)
+	cert, key, err := ca.Issue([]string{"short.test"}, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, WritePair(certFile, keyFile, cert, key, false))
+	st, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), st.Mode().Perm())
+	st, err = os.Stat(certFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), st.Mode().Perm())
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Raw, pair.Certificate[0])
+
+	assert.ErrorIs(t, WritePair(certFile, keyFile, cert, key, false), os.ErrExist)
+	assert.NoError(t, WritePair(certFile, keyFile, cert, key, true))
+}
+
+func TestSelfSigned(t *testing.T) {
+	cert, err := SelfSigned([]string{"localhost", "::1"}, time.Hour)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert.Leaf)
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{DNSName: "::1", Roots: roots})
+	assert.NoError(t, err)
+}
+
+func TestHosts(t *testing.T) {
+	c := config.Default()
+	c.ServerAddress = "0.0.0.0:8443"
+	c.Domains = []config.DomainConfig{
+		{ID: "a", BaseURL: "https://a.test"},
+		{ID: "b", BaseURL: "https://localhost:8443"},
+	}
+	assert.Equal(t, []string{"localhost", "127.0.0.1", "::1", "a.test"}, Hosts(c))
+}
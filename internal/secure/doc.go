@@ -0,0 +1,2 @@
+// Package secure - https listener setup: certificate hot reload, version and cipher policy, HSTS and http redirect
+package secure
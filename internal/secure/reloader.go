@@ -0,0 +1,135 @@
+package secure
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Stas9132/shortener/internal/logger"
+)
+
+// CertReloader - serves certificate from files reloading it when their modification time changes
+type CertReloader struct {
+	logger logger.Logger
+
+	mu                sync.RWMutex
+	certFile, keyFile string
+	cert              *tls.Certificate
+	modTime           time.Time
+	// ephemeral - cert is generated, files are loaded once both exist
+	ephemeral bool
+}
+
+// NewCertReloader - constructor, loads certificate and checks files for changes every interval until ctx is done.
+// Zero interval disables reloading.
+func NewCertReloader(ctx context.Context, l logger.Logger, certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	cr := &CertReloader{logger: l}
+	if err := cr.SetFiles(certFile, keyFile); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go cr.watch(ctx, interval)
+	}
+	return cr, nil
+}
+
+// newEphemeralReloader - serves generated cert until certificate and key appear at certFile and keyFile
+func newEphemeralReloader(ctx context.Context, l logger.Logger, cert *tls.Certificate, certFile, keyFile string, interval time.Duration) *CertReloader {
+	cr := &CertReloader{logger: l, certFile: certFile, keyFile: keyFile, cert: cert, ephemeral: true}
+	if interval > 0 {
+		go cr.watch(ctx, interval)
+	}
+	return cr
+}
+
+// filesModTime - latest modification time of certificate and key files
+func filesModTime(certFile, keyFile string) (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{certFile, keyFile} {
+		st, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if st.ModTime().After(latest) {
+			latest = st.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// files - current certificate and key paths, ephemeral is set while they are not loaded yet
+func (cr *CertReloader) files() (certFile, keyFile string, ephemeral bool) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.certFile, cr.keyFile, cr.ephemeral
+}
+
+// Reload - rereads certificate and key, on error previous certificate is kept
+func (cr *CertReloader) Reload() error {
+	certFile, keyFile, _ := cr.files()
+	return cr.SetFiles(certFile, keyFile)
+}
+
+// SetFiles - loads certificate and key from new paths and serves them from now on, on error previous ones are kept.
+// Ephemeral certificate is kept until files appear at new paths.
+func (cr *CertReloader) SetFiles(certFile, keyFile string) error {
+	modTime, err := filesModTime(certFile, keyFile)
+	var cert tls.Certificate
+	if err == nil {
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	}
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	if err != nil {
+		if cr.ephemeral {
+			cr.certFile, cr.keyFile = certFile, keyFile
+		}
+		return err
+	}
+	cr.certFile, cr.keyFile, cr.cert, cr.modTime, cr.ephemeral = certFile, keyFile, &cert, modTime, false
+	return nil
+}
+
+func (cr *CertReloader) watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		certFile, keyFile, ephemeral := cr.files()
+		modTime, err := filesModTime(certFile, keyFile)
+		if err != nil {
+			if !ephemeral {
+				cr.logger.WithField("error", err).Warn("Error while stat certificate")
+			}
+			continue
+		}
+		cr.mu.RLock()
+		same := modTime.Equal(cr.modTime)
+		cr.mu.RUnlock()
+		if same {
+			continue
+		}
+		if err = cr.Reload(); err != nil {
+			cr.logger.WithField("error", err).Warn("Error while reload certificate")
+			continue
+		}
+		if ephemeral {
+			cr.logger.WithField("cert", certFile).Info("Certificate found, replaced ephemeral one")
+			continue
+		}
+		cr.logger.WithField("cert", certFile).Info("Certificate reloaded")
+	}
+}
+
+// GetCertificate - for tls.Config.GetCertificate
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
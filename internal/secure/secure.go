@@ -0,0 +1,122 @@
+package secure
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/logger"
+)
+
+// ephemeralValidity - lifetime of certificate generated when configured one is missing
+const ephemeralValidity = 7 * 24 * time.Hour
+
+// TLSConfig - server tls config with certificate served by reloader and version and cipher policy from cfg,
+// certificate paths changed by config reload are loaded at once.
+// When neither certificate nor key file exists serves ephemeral self-signed certificate for hosts
+// until they appear.
+// With client CA bundle configured client certificates are verified when given, routes decide whether they are required.
+func TLSConfig(ctx context.Context, l logger.Logger, cfg *config.Holder, hosts []string) (*tls.Config, error) {
+	c := cfg.Get().TLS
+	tc := &tls.Config{MinVersion: config.TLSVersion(c.MinVersion)}
+	cr, err := NewCertReloader(ctx, l, c.CertFile, c.KeyFile, time.Duration(c.ReloadInterval))
+	switch {
+	case err == nil:
+	case missing(c.CertFile) && missing(c.KeyFile):
+		cert, err := SelfSigned(hosts, ephemeralValidity)
+		if err != nil {
+			return nil, err
+		}
+		l.WithFields(map[string]interface{}{
+			"cert":  c.CertFile,
+			"hosts": hosts,
+		}).Warn("Certificate not found, serving ephemeral self-signed certificate")
+		// files appearing at configured or reloaded paths replace it
+		cr = newEphemeralReloader(ctx, l, &cert, c.CertFile, c.KeyFile, time.Duration(c.ReloadInterval))
+	default:
+		return nil, err
+	}
+	tc.GetCertificate = cr.GetCertificate
+	context.AfterFunc(ctx, cfg.Subscribe(func(old, new config.Config) {
+		if old.TLS.CertFile == new.TLS.CertFile && old.TLS.KeyFile == new.TLS.KeyFile {
+			return
+		}
+		if err := cr.SetFiles(new.TLS.CertFile, new.TLS.KeyFile); err != nil {
+			l.WithField("error", err).Error("Error while load certificate, previous one is served")
+			return
+		}
+		l.WithField("cert", new.TLS.CertFile).Info("Certificate changed")
+	}))
+	for _, name := range c.CipherSuites {
+		id, err := config.CipherSuite(name)
+		if err != nil {
+			return nil, err
+		}
+		tc.CipherSuites = append(tc.CipherSuites, id)
+	}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.ClientCAs = x509.NewCertPool()
+		if !tc.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", c.ClientCAFile)
+		}
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tc, nil
+}
+
+func missing(name string) bool {
+	_, err := os.Stat(name)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// HSTS - middleware setting Strict-Transport-Security on https requests, zero max-age disables it
+func HSTS(cfg config.TLSConfig) func(http.Handler) http.Handler {
+	value := "max-age=" + strconv.FormatInt(int64(time.Duration(cfg.HSTSMaxAge)/time.Second), 10)
+	if cfg.HSTSIncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	return func(h http.Handler) http.Handler {
+		if cfg.HSTSMaxAge <= 0 {
+			return h
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.TLS != nil {
+				w.Header().Set("Strict-Transport-Security", value)
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Redirect - handler redirecting to the same url over https served at httpsAddress
+func Redirect(httpsAddress string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddress)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		u := *r.URL
+		u.Scheme, u.Host = "https", host
+		http.Redirect(w, r, u.String(), http.StatusPermanentRedirect)
+	})
+}
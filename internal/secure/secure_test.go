@@ -0,0 +1,164 @@
+package secure
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes self-signed certificate for cn, modification time is set to mod
+func writeCert(t *testing.T, certFile, keyFile, cn string, mod time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	kb, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600))
+	require.NoError(t, os.Chtimes(certFile, mod, mod))
+	require.NoError(t, os.Chtimes(keyFile, mod, mod))
+}
+
+func commonName(t *testing.T, tc *tls.Config) string {
+	cert, err := tc.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default().TLS
+	cfg.CertFile, cfg.KeyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	cfg.ReloadInterval = config.Duration(10 * time.Millisecond)
+	cfg.MinVersion = "1.3"
+	cfg.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, os.WriteFile(cfg.KeyFile, []byte("broken"), 0600))
+	holder := func() *config.Holder {
+		c := config.Default()
+		c.TLS = cfg
+		return config.NewHolder(c)
+	}
+	_, err := TLSConfig(ctx, logger.NewDummy(), holder(), nil)
+	assert.Error(t, err, "key without certificate")
+	require.NoError(t, os.Remove(cfg.KeyFile))
+
+	h := holder()
+	tc, err := TLSConfig(ctx, logger.NewDummy(), h, []string{"ephemeral.test"})
+	require.NoError(t, err, "missing files give ephemeral certificate")
+	assert.Equal(t, "ephemeral.test", commonName(t, tc))
+	other := h.Get()
+	other.TLS.CertFile, other.TLS.KeyFile = filepath.Join(dir, "other.crt"), filepath.Join(dir, "other.key")
+	writeCert(t, other.TLS.CertFile, other.TLS.KeyFile, "other.test", time.Now())
+	h.Update(other)
+	assert.Equal(t, "other.test", commonName(t, tc), "paths changed by reload replace ephemeral certificate")
+
+	placed := cfg
+	placed.CertFile, placed.KeyFile = filepath.Join(dir, "placed.crt"), filepath.Join(dir, "placed.key")
+	c := config.Default()
+	c.TLS = placed
+	tc, err = TLSConfig(ctx, logger.NewDummy(), config.NewHolder(c), []string{"ephemeral.test"})
+	require.NoError(t, err)
+	assert.Equal(t, "ephemeral.test", commonName(t, tc))
+	writeCert(t, placed.CertFile, placed.KeyFile, "placed.test", time.Now())
+	assert.Eventually(t, func() bool { return commonName(t, tc) == "placed.test" }, time.Second, 10*time.Millisecond,
+		"files placed at configured paths replace ephemeral certificate")
+
+	start := time.Now().Add(-time.Minute)
+	writeCert(t, cfg.CertFile, cfg.KeyFile, "old.test", start)
+	h = holder()
+	tc, err = TLSConfig(ctx, logger.NewDummy(), h, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), tc.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, tc.CipherSuites)
+	assert.Equal(t, "old.test", commonName(t, tc))
+
+	require.NoError(t, os.WriteFile(cfg.KeyFile, []byte("broken"), 0600))
+	require.NoError(t, os.Chtimes(cfg.KeyFile, start.Add(time.Second), start.Add(time.Second)))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "old.test", commonName(t, tc), "broken key keeps previous certificate")
+
+	writeCert(t, cfg.CertFile, cfg.KeyFile, "new.test", start.Add(2*time.Second))
+	assert.Eventually(t, func() bool { return commonName(t, tc) == "new.test" }, time.Second, 10*time.Millisecond)
+
+	c = h.Get()
+	c.TLS.CertFile = filepath.Join(dir, "missing.crt")
+	h.Update(c)
+	assert.Equal(t, "new.test", commonName(t, tc), "unloadable paths keep previous certificate")
+	c.TLS.CertFile, c.TLS.KeyFile = other.TLS.CertFile, other.TLS.KeyFile
+	h.Update(c)
+	assert.Equal(t, "other.test", commonName(t, tc), "paths changed by reload")
+}
+
+func TestHSTS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name   string
+		cfg    config.TLSConfig
+		target string
+		want   string
+	}{
+		{name: "Disabled", target: "https://sho.rt/"},
+		{name: "Plain http", cfg: config.TLSConfig{HSTSMaxAge: config.Duration(time.Hour)}, target: "http://sho.rt/"},
+		{name: "Max age", cfg: config.TLSConfig{HSTSMaxAge: config.Duration(24 * time.Hour)}, target: "https://sho.rt/", want: "max-age=86400"},
+		{name: "Subdomains", cfg: config.TLSConfig{HSTSMaxAge: config.Duration(time.Hour), HSTSIncludeSubdomains: true},
+			target: "https://sho.rt/", want: "max-age=3600; includeSubDomains"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			HSTS(tt.cfg)(ok).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.want, w.Header().Get("Strict-Transport-Security"))
+		})
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		target  string
+		want    string
+	}{
+		{name: "Default port", address: ":443", target: "http://sho.rt:80/abc?x=1", want: "https://sho.rt/abc?x=1"},
+		{name: "Custom port", address: "localhost:8443", target: "http://sho.rt/abc", want: "https://sho.rt:8443/abc"},
+		{name: "IPv6", address: ":8443", target: "http://[::1]/abc", want: "https://[::1]:8443/abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			Redirect(tt.address).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+			assert.Equal(t, tt.want, w.Header().Get("Location"))
+		})
+	}
+}
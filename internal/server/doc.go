@@ -0,0 +1,2 @@
+// Package server - shortener application assembled from config, used by main and integration tests
+package server
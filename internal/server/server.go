@@ -0,0 +1,160 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/app/handlers"
+	"github.com/Stas9132/shortener/internal/app/handlers/middleware"
+	strg "github.com/Stas9132/shortener/internal/app/storage"
+	"github.com/Stas9132/shortener/internal/compress"
+	"github.com/Stas9132/shortener/internal/health"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/Stas9132/shortener/internal/metrics"
+	"github.com/Stas9132/shortener/internal/secure"
+	"github.com/Stas9132/shortener/internal/tracing"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Server - shortener instance, http.Handler serving public api
+type Server struct {
+	storage strg.StorageI
+	api     handlers.APIT
+	health  *health.Registry
+	router  chi.Router
+}
+
+// New - opens storage selected by cfg, registers its readiness checks and builds router, Close releases storage
+func New(ctx context.Context, l logger.Logger, cfg *config.Holder) (*Server, error) {
+	c := cfg.Get()
+	h := health.New(time.Duration(c.Health.CacheTTL))
+	timeout := time.Duration(c.Health.Timeout)
+	var st strg.StorageI
+	var subs []strg.ChangeSubscriber
+	if c.DatabaseDsn == "" {
+		fs, err := strg.NewFileStorage(ctx, l, c)
+		if err != nil {
+			return nil, err
+		}
+		st = strg.NewTraced(fs, "file")
+		h.Register("storage_writable", timeout, fs.CheckWritable)
+	} else {
+		db, err := strg.NewDB(ctx, l, c)
+		if err != nil {
+			return nil, err
+		}
+		st = strg.NewTraced(db, "db")
+		h.Register("migrations", timeout, db.CheckMigrations)
+		if c.Bloom.Enabled {
+			b := strg.NewBloom(ctx, l, st, db.Keys, c.Bloom.FalsePositiveRate, c.Bloom.Capacity)
+			if c.Bloom.RebuildInterval > 0 {
+				go b.Run(ctx, time.Duration(c.Bloom.RebuildInterval))
+			}
+			subs = append(subs, b)
+			st = b
+		}
+	}
+	h.Register("storage", timeout, st.Ping)
+	if c.Cache.Size > 0 {
+		cached := strg.NewCached(st, c.Cache.Size, time.Duration(c.Cache.TTL), time.Duration(c.Cache.NegativeTTL))
+		if c.DatabaseDsn != "" {
+			subs = append(subs, strg.CacheSubscriber(cached, time.Duration(c.Cache.FallbackTTL)))
+		}
+		st = cached
+	}
+	if len(subs) > 0 {
+		// other instances sharing the database report their changes
+		go strg.NewChangeListener(l, c.DatabaseDsn, subs...).Run(ctx)
+	}
+	api := handlers.NewAPI(ctx, l, cfg, st)
+	if limit := int64(c.Health.MaxDeleteBacklog); limit > 0 {
+		h.Register("delete_backlog", timeout, func(context.Context) error {
+			if n := api.DeleteBacklog(); n > limit {
+				return fmt.Errorf("%d keys waiting for deletion, limit %d", n, limit)
+			}
+			return nil
+		})
+	}
+	return &Server{
+		storage: st,
+		api:     api,
+		health:  h,
+		router:  NewRouter(l, api, h.Summary(), cfg),
+	}, nil
+}
+
+// storageUser - passes issuer to storage, reads of issuers who just created links go to primary database
+func storageUser(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(strg.WithUser(r.Context(), middleware.GetIssuer(r.Context()).ID)))
+	})
+}
+
+// NewRouter - public api routes with middleware chain logging requests to l, ready answers readiness probes
+func NewRouter(l logger.Logger, handler handlers.APII, ready http.Handler, cfg *config.Holder) chi.Router {
+	c := cfg.Get()
+	r := chi.NewRouter()
+	if c.ServesTLS() {
+		r.Use(secure.HSTS(c.TLS))
+	}
+	r.Use(middleware.RequestID(l), tracing.Middleware, metrics.Middleware, middleware.RequestLogger,
+		middleware.ClientCert(cfg), middleware.ClientCertPolicy(cfg), middleware.Authorization, compress.New(c.Compress), middleware.BasePath(cfg))
+	if len(c.Replicas.DSNs) > 0 {
+		r.Use(storageUser)
+	}
+
+	r.Post("/", handler.PostPlainText)
+	r.Get("/{sn}", handler.GetRoot)
+	r.Post("/api/shorten", handler.PostJSON)
+	r.Post("/api/shorten/batch", handler.PostBatch)
+	r.Get("/api/user/urls", handler.GetUserURLs)
+	r.Delete("/api/user/urls", handler.DeleteUserUrls)
+	r.Get("/ping", handler.GetPing)
+	r.Method(http.MethodGet, "/healthz", health.Live())
+	r.Method(http.MethodGet, "/readyz", ready)
+	r.Post("/api/report/{code}", handler.PostReport)
+	// internal services authenticate with client certificates, see tls.client_cert_routes
+	r.Route("/api/internal", func(r chi.Router) {
+		r.Post("/shorten", handler.PostJSON)
+		r.Post("/shorten/batch", handler.PostBatch)
+	})
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(middleware.AdminOnly(cfg))
+		r.Get("/reports", handler.GetReports)
+		r.Post("/disable/{code}", handler.PostDisable)
+		r.Get("/audit", handler.GetAudit)
+		r.Get("/audit/verify", handler.GetAuditVerify)
+	})
+	r.NotFound(handler.Default)
+	r.MethodNotAllowed(handler.Default)
+	return r
+}
+
+// ServeHTTP - implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// Drain - fails readiness, called when shutdown begins
+func (s *Server) Drain() {
+	s.api.Drain()
+	s.health.Drain()
+}
+
+// Health - readiness checks of storage and request backlog
+func (s *Server) Health() *health.Registry {
+	return s.health
+}
+
+// Wait - waits for background work started by requests
+func (s *Server) Wait(ctx context.Context) error {
+	return s.api.Wait(ctx)
+}
+
+// Close - closes storage
+func (s *Server) Close() error {
+	return s.storage.Close()
+}
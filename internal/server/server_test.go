@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Stas9132/shortener/config"
+	"github.com/Stas9132/shortener/internal/logger"
+	"github.com/Stas9132/shortener/internal/secure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, f func(c *config.Config)) (*httptest.Server, *config.Holder) {
+	c := config.Default()
+	c.FileStoragePath = t.TempDir() + "/storage.json"
+	f(&c)
+	cfg := config.NewHolder(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := New(ctx, logger.NewDummy(), cfg)
+	require.NoError(t, err)
+	ts := httptest.NewServer(s)
+	t.Cleanup(func() {
+		ts.Close()
+		cancel()
+		s.Close()
+	})
+	return ts, cfg
+}
+
+var noRedirect = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}}
+
+func shorten(t *testing.T, ts *httptest.Server, dest string) string {
+	resp, err := noRedirect.Post(ts.URL+"/", "text/plain", strings.NewReader(dest))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestServer_Isolated(t *testing.T) {
+	a, _ := newTestServer(t, func(c *config.Config) { c.BaseURL = "http://a.test/" })
+	b, _ := newTestServer(t, func(c *config.Config) { c.BaseURL = "http://b.test/" })
+
+	short := shorten(t, a, "https://go.dev/")
+	assert.True(t, strings.HasPrefix(short, "http://a.test/"), short)
+	assert.True(t, strings.HasPrefix(shorten(t, b, "https://go.dev/"), "http://b.test/"))
+
+	code := short[strings.LastIndex(short, "/"):]
+	resp, err := noRedirect.Get(a.URL + code)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	assert.Equal(t, "https://go.dev/", resp.Header.Get("Location"))
+
+	resp, err = noRedirect.Get(b.URL + "/" + strings.Repeat("f", 8))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+}
+
+func TestServer_ConfigUpdate(t *testing.T) {
+	ts, cfg := newTestServer(t, func(c *config.Config) { c.AdminToken = "old" })
+	reports := func(token string) int {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/admin/reports", nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Admin-Token", token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusNoContent, reports("old"))
+
+	c := cfg.Get()
+	c.AdminToken = "new"
+	c.BaseURL = "http://c.test/"
+	cfg.Update(c)
+	assert.Equal(t, http.StatusForbidden, reports("old"))
+	assert.Equal(t, http.StatusNoContent, reports("new"))
+	assert.True(t, strings.HasPrefix(shorten(t, ts, "https://go.dev/"), "http://c.test/"))
+}
+
+// issue creates certificate signed by parent key, self-signed when parent is nil, and writes pem files to dir
+func issue(t *testing.T, dir, name string, tmpl *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore, tmpl.NotAfter = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	kb, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0600))
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func TestServer_ClientCert(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := issue(t, dir, "ca", &x509.Certificate{
+		Subject: pkix.Name{CommonName: "test ca"}, IsCA: true, BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}, nil, nil)
+	issue(t, dir, "server", &x509.Certificate{
+		Subject: pkix.Name{CommonName: "server"}, IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	svc, _ := url.Parse("spiffe://cluster/analytics")
+	issue(t, dir, "client", &x509.Certificate{
+		Subject: pkix.Name{CommonName: "analytics"}, URIs: []*url.URL{svc},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+
+	c := config.Default()
+	c.FileStoragePath = filepath.Join(dir, "storage.json")
+	c.SecureConnection = true
+	c.TLS.CertFile, c.TLS.KeyFile = filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	c.TLS.ClientCAFile = filepath.Join(dir, "ca.crt")
+	c.TLS.ClientIdentities = map[string]string{svc.String(): "svc-analytics"}
+	require.NoError(t, c.Validate())
+	cfg := config.NewHolder(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := New(ctx, logger.NewDummy(), cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	tc, err := secure.TLSConfig(ctx, logger.NewDummy(), cfg, nil)
+	require.NoError(t, err)
+	// StartTLS would replace certificate from GetCertificate with its own
+	ts := httptest.NewUnstartedServer(s)
+	ts.Listener = tls.NewListener(ts.Listener, tc)
+	ts.Start()
+	defer ts.Close()
+	base := "https://" + ts.Listener.Addr().String()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	require.NoError(t, err)
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	service := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}}}}
+
+	resp, err := anonymous.Post(base+"/api/internal/shorten", "application/json", strings.NewReader(`{"url":"https://go.dev/"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = anonymous.Post(base+"/", "text/plain", strings.NewReader("https://go.dev/blog/"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "public routes need no certificate")
+	assert.Empty(t, resp.Header.Get("Strict-Transport-Security"), "hsts disabled by default")
+
+	resp, err = service.Post(base+"/api/internal/shorten", "application/json", strings.NewReader(`{"url":"https://go.dev/"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Set-Cookie"), "certificate clients get no auth cookie")
+
+	resp, err = service.Get(base + "/api/user/urls")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var urls []map[string]string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&urls))
+	require.Len(t, urls, 1)
+	assert.Equal(t, "https://go.dev/", urls[0]["original_url"])
+}
+
+func TestServer_Health(t *testing.T) {
+	c := config.Default()
+	dir := t.TempDir()
+	c.FileStoragePath = dir + "/storage.json"
+	c.Health.CacheTTL = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := New(ctx, logger.NewDummy(), config.NewHolder(c))
+	require.NoError(t, err)
+	defer s.Close()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	// detailed report is served on admin listener only
+	admin := httptest.NewServer(s.Health())
+	defer admin.Close()
+	probe := func(url string) (int, map[string]interface{}) {
+		resp, err := http.Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+
+	code, body := probe(admin.URL)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, body["checks"], 3, "storage, storage_writable and delete_backlog")
+	code, body = probe(ts.URL + "/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"status": "ok"}, body)
+
+	require.NoError(t, os.RemoveAll(dir))
+	code, body = probe(admin.URL)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", body["checks"].(map[string]interface{})["storage_writable"].(map[string]interface{})["status"])
+	code, body = probe(ts.URL + "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, map[string]interface{}{"status": "fail"}, body, "public probe hides check errors")
+
+	s.Drain()
+	code, _ = probe(ts.URL + "/healthz")
+	assert.Equal(t, http.StatusOK, code)
+}
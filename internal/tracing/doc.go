@@ -0,0 +1,2 @@
+// Package tracing - OpenTelemetry tracing of requests and storage calls
+package tracing
@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setup(t *testing.T) *tracetest.InMemoryExporter {
+	exp := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return exp
+}
+
+func TestMiddleware(t *testing.T) {
+	exp := setup(t)
+	var outgoing http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoing = r.Header.Clone()
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: Transport{}}
+
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/{sn}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "storage.Load")
+		span.End()
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Contains(t, w.Header().Get("traceparent"), traceID)
+	assert.Contains(t, outgoing.Get("traceparent"), traceID)
+
+	spans := exp.GetSpans()
+	require.Len(t, spans, 3)
+	names := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		assert.Equal(t, traceID, s.SpanContext.TraceID().String())
+		names[s.Name] = s
+	}
+	server, ok := names["GET /{sn}"]
+	require.True(t, ok)
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, server.SpanContext.SpanID(), names["storage.Load"].Parent.SpanID())
+	assert.Equal(t, server.SpanContext.SpanID(), names["HTTP GET"].Parent.SpanID())
+}